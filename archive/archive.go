@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// โฟลเดอร์ย่อยของสำเนาตามผลการประมวลผล (ชื่อเดียวกับค่าเริ่มต้นของโฟลเดอร์บน SFTP server)
+const (
+	Success = "archive"
+	Failure = "error"
+)
+
+// Store เก็บสำเนาบีบอัดของไฟล์ที่ประมวลผลแล้วใน <Dir>/<archive|error>/yyyy/mm/dd
+type Store struct {
+	Dir       string
+	Retention time.Duration // สำเนาที่เก่ากว่านี้จะถูกลบโดย Prune (0 = เก็บไว้ตลอด)
+}
+
+// Save บีบอัดไฟล์ srcPath ด้วย gzip ไว้ในโฟลเดอร์ของวันที่ now ตามผล outcome (Success หรือ Failure)
+// และคืน path ของสำเนา ถ้ามีสำเนาชื่อเดียวกันในวันนั้นแล้ว (เช่น ประมวลผลซ้ำ) จะต่อท้ายชื่อด้วยเวลา
+func (s Store) Save(srcPath, outcome string, now time.Time) (string, error) {
+	dir := filepath.Join(s.Dir, outcome, now.Format("2006"), now.Format("01"), now.Format("02"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("ไม่สามารถสร้างโฟลเดอร์ %s ได้: %v", dir, err)
+	}
+
+	name := filepath.Base(srcPath)
+	target := filepath.Join(dir, name+".gz")
+	if _, err := os.Stat(target); err == nil {
+		target = filepath.Join(dir, name+"."+now.Format("150405")+".gz")
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return "", fmt.Errorf("ไม่สามารถเปิดไฟล์ %s ได้: %v", srcPath, err)
+	}
+	defer src.Close()
+
+	// เขียนลงไฟล์ชั่วคราวก่อน เพื่อไม่ให้เหลือสำเนาที่ไม่สมบูรณ์ถ้าเขียนไม่สำเร็จ
+	tmp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("ไม่สามารถสร้างไฟล์ในโฟลเดอร์ %s ได้: %v", dir, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	zw.Name = name
+	if info, err := src.Stat(); err == nil {
+		zw.ModTime = info.ModTime()
+	}
+	if _, err := io.Copy(zw, src); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("ไม่สามารถบีบอัดไฟล์ %s ได้: %v", name, err)
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("ไม่สามารถบีบอัดไฟล์ %s ได้: %v", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("ไม่สามารถบันทึกไฟล์ %s ได้: %v", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", fmt.Errorf("ไม่สามารถบันทึกไฟล์ %s ได้: %v", target, err)
+	}
+	return target, nil
+}
+
+// Prune ลบสำเนาที่แก้ไขล่าสุดก่อน now - Retention และโฟลเดอร์วันที่ที่ว่างแล้ว และคืนจำนวนไฟล์ที่ลบ
+func (s Store) Prune(now time.Time) (int, error) {
+	if s.Retention <= 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-s.Retention)
+
+	removed := 0
+	var dirs []string
+	for _, outcome := range []string{Success, Failure} {
+		root := filepath.Join(s.Dir, outcome)
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == root {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if entry.IsDir() {
+				if path != root {
+					dirs = append(dirs, path)
+				}
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if info.ModTime().Before(cutoff) {
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+				removed++
+			}
+			return nil
+		})
+		if err != nil {
+			return removed, fmt.Errorf("ไม่สามารถลบสำเนาที่หมดอายุใน %s ได้: %v", root, err)
+		}
+	}
+
+	// ลบโฟลเดอร์ที่ว่างจากชั้นในสุดออกมา (os.Remove ไม่ลบโฟลเดอร์ที่ยังมีไฟล์)
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		os.Remove(dir)
+	}
+	return removed, nil
+}
@@ -0,0 +1,128 @@
+package archive
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readGzip คืนเนื้อหาของไฟล์ gzip ที่ path
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStoreSave(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "saleorder_item_20250514.csv")
+	if err := os.WriteFile(src, []byte("DocNo|ItemId\nSO1|1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := Store{Dir: t.TempDir()}
+	now := time.Date(2025, 5, 14, 9, 30, 15, 0, time.Local)
+
+	tests := []struct {
+		outcome string
+		want    string
+	}{
+		{Success, filepath.Join(store.Dir, "archive", "2025", "05", "14", "saleorder_item_20250514.csv.gz")},
+		{Failure, filepath.Join(store.Dir, "error", "2025", "05", "14", "saleorder_item_20250514.csv.gz")},
+		// สำเนาชื่อเดียวกันในวันเดียวกันต่อท้ายด้วยเวลา
+		{Success, filepath.Join(store.Dir, "archive", "2025", "05", "14", "saleorder_item_20250514.csv.093015.gz")},
+	}
+
+	for _, tt := range tests {
+		got, err := store.Save(src, tt.outcome, now)
+		if err != nil {
+			t.Fatalf("Save(%s): %v", tt.outcome, err)
+		}
+		if got != tt.want {
+			t.Errorf("Save(%s) = %s ต้องการ %s", tt.outcome, got, tt.want)
+		}
+		if content := readGzip(t, got); content != "DocNo|ItemId\nSO1|1\n" {
+			t.Errorf("เนื้อหาของ %s = %q", got, content)
+		}
+	}
+
+	// ต้องไม่เหลือไฟล์ชั่วคราว
+	entries, err := os.ReadDir(filepath.Join(store.Dir, "archive", "2025", "05", "14"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("มี %d ไฟล์ในโฟลเดอร์ ต้องการ 2", len(entries))
+	}
+
+	if _, err := store.Save(filepath.Join(t.TempDir(), "missing.csv"), Success, now); err == nil {
+		t.Errorf("Save ต้องคืน error เมื่อไม่พบไฟล์ต้นทาง")
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	now := time.Date(2025, 5, 30, 12, 0, 0, 0, time.Local)
+	dir := t.TempDir()
+	files := map[string]time.Time{
+		filepath.Join(dir, "archive", "2025", "05", "01", "old.csv.gz"):  now.AddDate(0, 0, -29),
+		filepath.Join(dir, "error", "2025", "05", "02", "old.csv.gz"):    now.AddDate(0, 0, -28),
+		filepath.Join(dir, "archive", "2025", "05", "29", "new.csv.gz"):  now.AddDate(0, 0, -1),
+		filepath.Join(dir, "archive", "2025", "05", "01", "keep.csv.gz"): now.AddDate(0, 0, -1),
+	}
+	for path, modTime := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Retention = 0 ไม่ลบอะไร
+	if removed, err := (Store{Dir: dir}).Prune(now); err != nil || removed != 0 {
+		t.Errorf("Prune โดยไม่กำหนด Retention = %d, %v ต้องการ 0, nil", removed, err)
+	}
+
+	removed, err := Store{Dir: dir, Retention: 7 * 24 * time.Hour}.Prune(now)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Prune ลบ %d ไฟล์ ต้องการ 2", removed)
+	}
+
+	exists := map[string]bool{
+		filepath.Join(dir, "archive", "2025", "05", "01", "old.csv.gz"):  false,
+		filepath.Join(dir, "archive", "2025", "05", "01", "keep.csv.gz"): true,
+		filepath.Join(dir, "archive", "2025", "05", "29", "new.csv.gz"):  true,
+		filepath.Join(dir, "error", "2025", "05", "02"):                  false, // โฟลเดอร์ว่างถูกลบ
+		filepath.Join(dir, "error"):                                      true,
+	}
+	for path, want := range exists {
+		_, err := os.Stat(path)
+		if got := err == nil; got != want {
+			t.Errorf("%s มีอยู่ = %v ต้องการ %v", path, got, want)
+		}
+	}
+
+	// โฟลเดอร์ที่ยังไม่เคยสร้างไม่ใช่ข้อผิดพลาด
+	if _, err := (Store{Dir: t.TempDir(), Retention: time.Hour}).Prune(now); err != nil {
+		t.Errorf("Prune ของโฟลเดอร์ว่าง: %v", err)
+	}
+}
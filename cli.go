@@ -0,0 +1,482 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mcmc/config"
+	"mcmc/database"
+	"mcmc/process"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// คำสั่งที่รองรับ ถ้าไม่ระบุคำสั่งจะใช้ serve หรือ run ตาม cron.run_once
+const (
+	cmdServe      = "serve"
+	cmdRun        = "run"
+	cmdIngest     = "ingest"
+	cmdListRemote = "list-remote"
+	cmdHistory    = "history"
+	cmdMigrate    = "migrate"
+	cmdReprocess  = "reprocess"
+)
+
+const usage = `วิธีใช้: mcmc [คำสั่ง] [flags] [arguments]
+
+คำสั่ง:
+  serve               รันตาม cron schedule จนได้รับ SIGINT/SIGTERM
+  run                 รันหนึ่งรอบแล้วจบ
+  ingest <file>...    ประมวลผลไฟล์บนเครื่องโดยไม่ผ่าน SFTP
+  reprocess <name>... ลบข้อมูลเดิมของไฟล์ (หรือชุดไฟล์เมื่อใช้ -batch) แล้วประมวลผลซ้ำ
+  list-remote         แสดงไฟล์บน SFTP server ที่ตรงกับ file_types และ feed พร้อมสถานะการประมวลผล
+  history             แสดงประวัติการประมวลผลไฟล์ (file_processing_attempts)
+  migrate [up|down|status]
+                      ทำ migration ที่ค้างอยู่ (up, ค่าเริ่มต้น), ย้อน migration ล่าสุด (down)
+                      หรือแสดงสถานะของ migration แต่ละเวอร์ชัน (status)
+
+ถ้าไม่ระบุคำสั่งจะใช้ serve หรือ run ตาม cron.run_once
+ใช้ "mcmc <คำสั่ง> -h" เพื่อดู flags ของแต่ละคำสั่ง
+`
+
+// options คือ flags ของคำสั่ง flags ที่ตั้งค่าแทน config จะถูกรวมไว้ใน overrides
+type options struct {
+	configPath string
+	overrides  overrideFlag
+	args       []string
+
+	// history
+	limit  int
+	status string
+	file   string
+
+	// ingest
+	force bool
+
+	// reprocess
+	batch   bool
+	fromDir string
+
+	// migrate
+	to    int
+	steps int
+}
+
+// overrideFlag รับ -set key=value ได้หลายครั้ง โดย key ใช้ชื่อเดียวกับในไฟล์ config เช่น cron.schedule
+type overrideFlag map[string]string
+
+func (o overrideFlag) String() string {
+	parts := make([]string, 0, len(o))
+	for k, v := range o {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (o overrideFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(k) == "" {
+		return fmt.Errorf("ต้องอยู่ในรูปแบบ key=value")
+	}
+	o[strings.TrimSpace(k)] = v
+	return nil
+}
+
+// splitCommand แยกชื่อคำสั่งออกจาก arguments (คืนคำสั่งว่างถ้า argument แรกเป็น flag)
+func splitCommand(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "", args
+}
+
+// parseOptions อ่าน flags ของคำสั่ง cmd
+func parseOptions(cmd string, args []string) (*options, error) {
+	opts := &options{overrides: overrideFlag{}}
+
+	name := cmd
+	if name == "" {
+		name = "mcmc"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		if cmd == "" {
+			fmt.Fprint(fs.Output(), usage)
+		}
+		fmt.Fprintf(fs.Output(), "\nflags ของ %s:\n", name)
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&opts.configPath, "config", "", "path ของไฟล์ config (.yaml, .toml, .json) ถ้าไม่ระบุจะใช้ค่าจาก "+config.ConfigPathEnv)
+	fs.Var(opts.overrides, "set", "ทับค่า config ในรูปแบบ key=value เช่น -set cron.schedule='0 * * * *' (ระบุได้หลายครั้ง)")
+
+	// flags ที่ตั้งค่าแทน config แต่ละตัว
+	settings := map[string]*string{}
+	setting := func(flagName, key, help string) {
+		settings[key] = fs.String(flagName, "", help+" (ทับ "+key+")")
+	}
+
+	switch cmd {
+	case "", cmdServe, cmdRun:
+		if cmd != cmdRun {
+			setting("schedule", "cron.schedule", "cron expression")
+		}
+		setting("download-dir", "app.download_dir", "โฟลเดอร์สำหรับเก็บไฟล์ที่ดาวน์โหลด")
+		setting("remote-path", "sftp.remote_path", "โฟลเดอร์บน SFTP server")
+		setting("batch-size", "load.batch_size", "จำนวนแถวต่อช่วงที่บันทึกลงฐานข้อมูล")
+	case cmdIngest:
+		setting("batch-size", "load.batch_size", "จำนวนแถวต่อช่วงที่บันทึกลงฐานข้อมูล")
+		fs.BoolVar(&opts.force, "force", false, "ประมวลผลแม้ไฟล์เคยประมวลผลสำเร็จแล้ว")
+	case cmdListRemote:
+		setting("remote-path", "sftp.remote_path", "โฟลเดอร์บน SFTP server")
+	case cmdHistory:
+		fs.IntVar(&opts.limit, "limit", 50, "จำนวนรายการสูงสุด")
+		fs.StringVar(&opts.status, "status", "", "แสดงเฉพาะสถานะนี้ (success, failed)")
+		fs.StringVar(&opts.file, "file", "", "แสดงเฉพาะไฟล์ที่ชื่อมีข้อความนี้")
+	case cmdReprocess:
+		fs.BoolVar(&opts.batch, "batch", false, "argument เป็น batch ID และประมวลผลซ้ำทั้งชุด")
+		fs.StringVar(&opts.fromDir, "from", "", "อ่านไฟล์จากโฟลเดอร์นี้แทนการดาวน์โหลดจาก SFTP server")
+		setting("batch-size", "load.batch_size", "จำนวนแถวต่อช่วงที่บันทึกลงฐานข้อมูล")
+	case cmdMigrate:
+		fs.IntVar(&opts.to, "to", 0, "up: ทำ migration ถึงเวอร์ชันนี้ (0 = ทั้งหมด)")
+		fs.IntVar(&opts.steps, "steps", 1, "down: จำนวนเวอร์ชันที่ย้อน")
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return nil, fmt.Errorf("ไม่รู้จักคำสั่ง %s", cmd)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	opts.args = fs.Args()
+
+	// migrate รับ flags ได้ทั้งก่อนและหลัง action เช่น "mcmc migrate down -steps 2"
+	if cmd == cmdMigrate && len(opts.args) > 0 {
+		if err := fs.Parse(opts.args[1:]); err != nil {
+			return nil, err
+		}
+		opts.args = append([]string{opts.args[0]}, fs.Args()...)
+	}
+
+	for key, value := range settings {
+		if *value != "" {
+			opts.overrides[key] = *value
+		}
+	}
+
+	// คำสั่ง serve และ run กำหนดโหมดเอง โดยไม่สนใจ cron.run_once ในไฟล์ config
+	switch cmd {
+	case cmdServe:
+		opts.overrides["cron.run_once"] = "false"
+	case cmdRun, cmdIngest, cmdReprocess, cmdListRemote, cmdHistory, cmdMigrate:
+		opts.overrides["cron.run_once"] = "true"
+	}
+
+	takesArgs := cmd == cmdIngest || cmd == cmdReprocess
+	if takesArgs && len(opts.args) == 0 {
+		return nil, fmt.Errorf("ต้องระบุไฟล์ที่จะประมวลผล")
+	}
+	if cmd == cmdMigrate {
+		if len(opts.args) > 1 {
+			return nil, fmt.Errorf("คำสั่ง %s รับ argument ได้ไม่เกินหนึ่งตัว: %s", name, strings.Join(opts.args, " "))
+		}
+		if len(opts.args) == 1 && opts.args[0] != "up" && opts.args[0] != "down" && opts.args[0] != "status" {
+			return nil, fmt.Errorf("ไม่รู้จักคำสั่ง migrate %s (รองรับ up, down, status)", opts.args[0])
+		}
+		return opts, nil
+	}
+	if !takesArgs && len(opts.args) > 0 {
+		return nil, fmt.Errorf("คำสั่ง %s ไม่รับ argument: %s", name, strings.Join(opts.args, " "))
+	}
+	return opts, nil
+}
+
+// runCommand รันคำสั่ง cmd แล้วคืน exit code
+func runCommand(ctx context.Context, cmd string, cfg *config.Config, opts *options) int {
+	svc := newService(cfg)
+
+	// คำสั่งที่อ่านข้อมูลอย่างเดียวและคำสั่ง migrate เชื่อมต่อฐานข้อมูลโดยไม่ทำ migration อัตโนมัติ
+	var err error
+	switch cmd {
+	case cmdListRemote, cmdHistory, cmdMigrate:
+		err = svc.connectDB(ctx)
+	default:
+		err = svc.start(ctx)
+	}
+	// serve ไม่หยุดเมื่อฐานข้อมูลยังใช้งานไม่ได้ตอนเริ่ม แต่จะเชื่อมต่อและทำ migration ใหม่ในรอบถัดไป
+	if err != nil && cmd == cmdServe && ctx.Err() == nil {
+		log.Printf("ไม่สามารถเตรียมฐานข้อมูลได้ จะลองใหม่ในรอบถัดไป: %v", err)
+		err = nil
+	}
+	if err != nil {
+		svc.close()
+		if ctx.Err() != nil {
+			log.Printf("หยุดการทำงานก่อนเริ่มประมวลผล: %v", err)
+			return exitInterrupted
+		}
+		log.Printf("ไม่สามารถเริ่มต้นการทำงานได้: %v", err)
+		return exitFailure
+	}
+
+	code := exitOK
+	switch cmd {
+	case cmdServe:
+		code = serve(ctx, svc)
+	case cmdRun:
+		code = runOnce(ctx, svc)
+	case cmdMigrate:
+		action := "up"
+		if len(opts.args) > 0 {
+			action = opts.args[0]
+		}
+		code = exitCode(svc.migrate(os.Stdout, action, opts.to, opts.steps))
+	case cmdIngest:
+		code = exitCode(svc.ingest(ctx, opts.args, opts.force))
+	case cmdReprocess:
+		code = exitCode(svc.reprocess(ctx, opts.args, opts.batch, opts.fromDir))
+	case cmdListRemote:
+		code = exitCode(svc.listRemote(os.Stdout))
+	case cmdHistory:
+		code = exitCode(svc.history(os.Stdout, database.HistoryFilter{Filename: opts.file, Status: opts.status, Limit: opts.limit}))
+	}
+
+	// ถ้างานที่ค้างอยู่ไม่จบ การปิด connection pool จะรองานนั้น จึงออกทันที
+	if code != exitShutdownTimeout {
+		svc.close()
+	}
+	return code
+}
+
+// exitCode แสดง err (ถ้ามี) แล้วคืน exit code ที่ตรงกัน
+func exitCode(err error) int {
+	if err != nil {
+		log.Printf("ข้อผิดพลาด: %v", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// ingest ประมวลผลไฟล์บนเครื่องทีละไฟล์ ไฟล์ที่ตรงกับ feed ใช้ schema ของ feed
+// ส่วนไฟล์ saleorder ประมวลผลแยกรายไฟล์โดยไม่ตรวจสอบจำนวนรายการกับ summary ของชุด
+func (s *service) ingest(ctx context.Context, paths []string, force bool) error {
+	// ใช้ lock เดียวกับรอบปกติ เพื่อไม่ให้บันทึกซ้อนกับ instance ที่กำลังประมวลผลอยู่
+	lock, err := s.db.AcquireRunLock(s.cfg.Cron.LockName, s.cfg.Cron.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			log.Printf("ข้อผิดพลาด: %v", err)
+		}
+	}()
+
+	attempts := s.newAttemptLog()
+	failed := 0
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			log.Printf("ได้รับสัญญาณหยุด ไม่เริ่มไฟล์ถัดไป")
+			break
+		}
+
+		name := filepath.Base(path)
+		if !force {
+			processed, err := s.db.CheckFileProcessed(name)
+			if err != nil {
+				return err
+			}
+			if processed {
+				log.Printf("ข้ามไฟล์ %s เนื่องจากเคยประมวลผลสำเร็จแล้ว (ใช้ -force เพื่อประมวลผลซ้ำ)", name)
+				continue
+			}
+		}
+
+		startAttempts(attempts, name)
+		if feed := s.registry.Match(name); feed != nil {
+			err = loadFeedFile(s.cfg, s.db, attempts, feed, path, name, false)
+		} else {
+			err = loadFile(s.cfg, s.db, attempts, path, name, false)
+		}
+		if err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("ประมวลผลไม่สำเร็จ %d จาก %d ไฟล์", failed, len(paths))
+	}
+	return nil
+}
+
+// loadFile บันทึกไฟล์ saleorder บนเครื่องหนึ่งไฟล์ลงฐานข้อมูลภายใน transaction
+// replace = true จะลบข้อมูลที่เคยบันทึกจากไฟล์นี้ก่อนใน transaction เดียวกัน
+func loadFile(cfg *config.Config, db *database.DB, attempts *database.AttemptLog, localFilePath, name string, replace bool) error {
+	log.Printf("กำลังประมวลผลไฟล์ %s...", name)
+	var result *process.Result
+	err := db.RunInTransaction(func(tx *database.DB) error {
+		if replace {
+			if err := clearFile(tx, name); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		result, err = process.ProcessFile(tx, localFilePath, cfg)
+		return err
+	})
+	if err != nil {
+		if !process.IsKnown(err) {
+			err = fmt.Errorf("%w: %v", process.ErrPersist, err)
+		}
+		log.Printf("ประมวลผลไฟล์ %s ไม่สำเร็จ: %v", name, err)
+		if err := attempts.Finish(name, database.AttemptFailed, result.Stats(), err.Error()); err != nil {
+			log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลได้: %v", err)
+		}
+		return err
+	}
+
+	logResult(name, result)
+	if err := attempts.Finish(name, database.AttemptSuccess, result.Stats(), ""); err != nil {
+		log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลได้: %v", err)
+	}
+	return nil
+}
+
+// logResult แสดงผลการประมวลผลไฟล์ที่สำเร็จ
+func logResult(name string, result *process.Result) {
+	log.Printf("ประมวลผลไฟล์ %s สำเร็จ (อ่าน %d แถว, บันทึก %d แถว, ปฏิเสธ %d แถว, โหมด %s: insert %d, update %d, ไม่เปลี่ยนแปลง %d)",
+		name, result.RowsRead, result.RowsWritten, result.RowsRejected,
+		result.Load.Mode, result.Load.Inserted, result.Load.Updated, result.Load.Unchanged)
+	if result.RejectFile != "" {
+		log.Printf("แถวที่ไม่ผ่านการตรวจสอบของไฟล์ %s อยู่ที่ %s", name, result.RejectFile)
+	}
+}
+
+// listRemote แสดงไฟล์บน SFTP server ที่ตรงกับ file_types และ pattern ของ feed พร้อมสถานะการประมวลผล
+func (s *service) listRemote(out io.Writer) error {
+	if err := s.connectSFTP(); err != nil {
+		return err
+	}
+
+	type source struct {
+		kind   string
+		prefix string
+		list   func() ([]os.FileInfo, error)
+	}
+	var sources []source
+	for _, prefix := range s.cfg.App.FileTypes {
+		prefix := prefix
+		sources = append(sources, source{"saleorder", prefix, func() ([]os.FileInfo, error) {
+			return s.sftp.ListFilesByPrefix(prefix)
+		}})
+	}
+	for _, feed := range s.registry.Feeds() {
+		feed := feed
+		sources = append(sources, source{feed.Schema.Name, feed.Schema.Prefix(), func() ([]os.FileInfo, error) {
+			return s.sftp.ListFilesByPattern(feed.Schema.Pattern)
+		}})
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tTYPE\tSIZE\tMODIFIED\tSTATUS")
+	for _, src := range sources {
+		files, err := src.list()
+		if err != nil {
+			return err
+		}
+		statuses, err := s.db.FileStatusesByPrefix(src.prefix)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			status := "new"
+			if st, ok := statuses[file.Name()]; ok {
+				status = st.Status
+				if st.LastSuccessAt != nil {
+					status = "processed"
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", file.Name(), src.kind, file.Size(), file.ModTime().Format(time.DateTime), status)
+		}
+	}
+	return w.Flush()
+}
+
+// history แสดงประวัติการประมวลผลไฟล์ล่าสุดตาม filter
+func (s *service) history(out io.Writer, filter database.HistoryFilter) error {
+	logs, err := s.db.FileProcessingHistory(filter)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED\tFILE\tATTEMPT\tSTATUS\tDURATION\tBYTES\tSHA256\tREAD\tWRITTEN\tREJECTED\tMODE\tRUN\tHOST\tERROR")
+	for _, l := range logs {
+		sha := l.SHA256
+		if len(sha) > 12 {
+			sha = sha[:12]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%d\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\n",
+			l.StartedAt.Format(time.DateTime), l.Filename, l.Attempt, l.Status, time.Duration(l.DurationMs)*time.Millisecond,
+			l.Bytes, sha, l.RowsRead, l.RowsWritten, l.RowsRejected, l.LoadMode, l.RunID, l.Host, oneLine(l.ErrorMessage, 80))
+	}
+	return w.Flush()
+}
+
+// migrate ทำ migration ตาม action (up, down หรือ status)
+func (s *service) migrate(out io.Writer, action string, to, steps int) error {
+	switch action {
+	case "up":
+		count, err := s.db.MigrateUp(to)
+		if err != nil {
+			return err
+		}
+		log.Printf("ทำ migration เรียบร้อย (%d เวอร์ชัน)", count)
+		return nil
+	case "down":
+		count, err := s.db.MigrateDown(steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("ย้อน migration เรียบร้อย (%d เวอร์ชัน)", count)
+		return nil
+	}
+
+	statuses, err := s.db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED")
+	for _, m := range statuses {
+		status, applied := "pending", ""
+		switch {
+		case m.Missing:
+			status = "missing"
+		case m.Modified:
+			status = "modified"
+		case m.Applied:
+			status = "applied"
+		}
+		if m.AppliedAt != nil {
+			applied = m.AppliedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", m.Version, m.Name, status, applied)
+	}
+	return w.Flush()
+}
+
+// oneLine ย่อข้อความให้อยู่ในบรรทัดเดียวและยาวไม่เกิน max ตัวอักษร
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > max {
+		return string(r[:max-3]) + "..."
+	}
+	return s
+}
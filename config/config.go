@@ -1,58 +1,197 @@
 package config
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ConfigPathEnv คือชื่อ environment variable ที่ใช้ระบุ path ของไฟล์ config
+const ConfigPathEnv = "MCMC_CONFIG"
+
+// EnvPrefix คือ prefix ของ environment variable ที่ใช้ override ค่าใน config
+// เช่น MCMC_DATABASE_HOST, MCMC_SFTP_PASSWORD, MCMC_APP_FILE_TYPES
+const EnvPrefix = "MCMC"
 
 type Config struct {
-	Database DatabaseConfig
-	SFTP     SFTPConfig
-	App      AppConfig
-	Cron     CronConfig
+	Database   DatabaseConfig
+	SFTP       SFTPConfig
+	App        AppConfig
+	Archive    ArchiveConfig
+	Cron       CronConfig
+	Secrets    SecretsConfig
+	Load       LoadConfig
+	Validation ValidationConfig
+	Columns    ColumnsConfig
+	Feeds      []FeedSchema // feed เพิ่มเติมที่บันทึกด้วย loader ทั่วไป (รวมกับไฟล์ใน app.schema_dir)
 }
 
 type DatabaseConfig struct {
-	Driver   string
+	Driver   string // sqlserver, postgres, mysql หรือ sqlite
 	Host     string
-	Port     string
+	Port     string // ถ้าไม่ระบุจะใช้ port มาตรฐานของ driver
 	User     string
-	Password string
-	DBName   string
+	Password string // รองรับ secret reference เช่น file:/run/secrets/db_pw, env:DB_PW, vault-kv:path#key
+	DBName   string // ชื่อฐานข้อมูล หรือ path ของไฟล์เมื่อใช้ sqlite
+	AppName  string // ชื่อโปรแกรมที่ server เห็นในรายการ session
+
+	// ความปลอดภัยของการเชื่อมต่อ SQL Server
+	Encrypt                string // disable = ไม่เข้ารหัส, true = เข้ารหัสด้วย TLS, strict = TLS ตั้งแต่เริ่มเชื่อมต่อ (TDS 8.0)
+	CAFile                 string // path ของ CA certificate (PEM) ที่ใช้ตรวจสอบ certificate ของ server
+	ServerCertHostname     string // ชื่อ host ใน certificate ของ server ถ้าต่างจาก host
+	TrustServerCertificate bool   // ไม่ตรวจสอบ certificate ของ server (ใช้สำหรับทดสอบเท่านั้น)
+	Instance               string // named instance เช่น SQLEXPRESS (ถ้าไม่ระบุ port จะหา port จาก SQL Browser)
+
+	// การยืนยันตัวตนกับ SQL Server: sql = SQL login, ntlm = Windows account (user ในรูปแบบ DOMAIN\user),
+	// azure_ad = Azure AD ตามวิธีใน FedAuth
+	Authentication string
+	FedAuth        string // ActiveDirectoryDefault, ActiveDirectoryPassword, ActiveDirectoryServicePrincipal, ActiveDirectoryManagedIdentity หรือ ActiveDirectoryAzCli
+
+	// connection pool และเวลารอการเชื่อมต่อ
+	ConnectTimeout  time.Duration // 0 = ค่าเริ่มต้นของ driver
+	MaxOpenConns    int           // 0 = ไม่จำกัด
+	MaxIdleConns    int           // 0 = ไม่เก็บ connection ที่ว่างไว้
+	ConnMaxLifetime time.Duration // 0 = ไม่จำกัด
+	ConnMaxIdleTime time.Duration // 0 = ไม่จำกัด
+
+	// AutoMigrate ทำ migration ที่ยังไม่ได้ทำตอนเริ่มทำงาน ถ้าปิดไว้ต้องรัน "mcmc migrate up" เอง
+	// และโปรแกรมจะไม่เริ่มทำงานถ้ายังมี migration ค้างอยู่
+	AutoMigrate bool
 }
 
 type SFTPConfig struct {
 	Host       string
 	Port       string
 	User       string
-	Password   string
+	Password   string // รองรับ secret reference เช่นเดียวกับ DatabaseConfig.Password
 	RemotePath string
+
+	// การตรวจสอบ host key (ต้องกำหนดอย่างน้อยหนึ่งแบบ ถ้ากำหนดทั้งสองแบบต้องผ่านทั้งคู่)
+	KnownHostsFile        string   // path ของไฟล์ known_hosts
+	HostKeyFingerprints   []string // fingerprint ที่ยอมรับ เช่น SHA256:xxxx หรือ MD5:aa:bb:...
+	InsecureIgnoreHostKey bool     // ข้ามการตรวจสอบ host key (ใช้สำหรับทดสอบเท่านั้น)
+
+	// การยืนยันตัวตน
+	AuthMethods          []string // ลำดับวิธียืนยันตัวตน: publickey, agent, password (ว่าง = เลือกตามค่าที่กำหนดไว้)
+	PrivateKeyFile       string   // private key แบบ PEM หรือ OpenSSH
+	PrivateKeyPassphrase string   // รองรับ secret reference
+	AgentSocket          string   // ถ้าไม่ระบุจะใช้ SSH_AUTH_SOCK
+
+	// การตรวจสอบไฟล์ที่ดาวน์โหลดกับไฟล์ checksum <file>.sha256 หรือ <file>.md5 บนเซิร์ฟเวอร์
+	// none = ไม่ตรวจสอบ, optional = ตรวจสอบเมื่อมีไฟล์ checksum, required = ต้องมีไฟล์ checksum
+	ChecksumSidecar string
+}
+
+// EffectiveAuthMethods คืนลำดับวิธียืนยันตัวตนที่จะใช้จริง
+// ถ้าไม่ได้กำหนด AuthMethods จะใช้ publickey (ถ้ามี key) ตามด้วย password (ถ้ามีรหัสผ่าน)
+func (c SFTPConfig) EffectiveAuthMethods() []string {
+	if len(c.AuthMethods) > 0 {
+		return c.AuthMethods
+	}
+	var methods []string
+	if c.PrivateKeyFile != "" {
+		methods = append(methods, "publickey")
+	}
+	if c.Password != "" {
+		methods = append(methods, "password")
+	}
+	return methods
 }
 
 type AppConfig struct {
 	DownloadDir string
 	FileTypes   []string
+	SchemaDir   string // โฟลเดอร์ของไฟล์ schema ของ feed เพิ่มเติม (ว่าง = ไม่ใช้)
+}
+
+// ArchiveConfig กำหนดการจัดการไฟล์หลังประมวลผล แยกตามผล (สำเร็จ หรือไฟล์ไม่ผ่านการประมวลผล)
+// ไฟล์ที่ล้มเหลวเพราะดาวน์โหลดหรือบันทึกลงฐานข้อมูลไม่ได้จะไม่ถูกย้าย เพื่อให้ประมวลผลใหม่ในรอบถัดไป
+type ArchiveConfig struct {
+	// การจัดการไฟล์บน SFTP server: keep = ไม่ทำอะไร, move = ย้ายไปโฟลเดอร์ที่กำหนด, delete = ลบ
+	RemoteOnSuccess  string
+	RemoteOnFailure  string
+	RemoteSuccessDir string // โฟลเดอร์ปลายทางเมื่อ move (ถ้าไม่ใช่ absolute path จะอยู่ใต้ sftp.remote_path)
+	RemoteFailureDir string
+
+	// สำเนาบีบอัด (gzip) บนเครื่องใน <local_dir>/archive|error/yyyy/mm/dd/<file>.gz (ว่าง = ไม่เก็บ)
+	LocalDir       string
+	LocalRetention time.Duration // ลบสำเนาที่เก่ากว่านี้หลังจบแต่ละรอบ (0 = เก็บไว้ตลอด)
 }
 
 type CronConfig struct {
 	Schedule      string        // Cron expression สำหรับตั้งเวลาทำงาน (เช่น "0 */4 * * *" = ทุก 4 ชั่วโมง)
 	RunOnce       bool          // true = รันครั้งเดียวแล้วจบ, false = รันเป็น cron
-	RetryInterval time.Duration // เวลาที่จะลองใหม่ถ้าการเชื่อมต่อล้มเหลว
+	RetryInterval time.Duration // ระยะรอสูงสุดระหว่างการลองใหม่เมื่อเชื่อมต่อ อ่านรายการไฟล์ หรือดาวน์โหลดล้มเหลว
+
+	// การลองใหม่เมื่อเกิดข้อผิดพลาดชั่วคราว ระยะรอเริ่มจาก RetryBaseDelay และเพิ่มเป็นสองเท่าจนถึง RetryInterval
+	RetryAttempts  int           // จำนวนครั้งสูงสุดรวมครั้งแรก (1 = ไม่ลองใหม่)
+	RetryBaseDelay time.Duration // ระยะรอก่อนลองใหม่ครั้งแรก
+
+	// lock ระดับฐานข้อมูลที่ป้องกันไม่ให้หลาย instance ประมวลผลพร้อมกัน
+	LockName    string        // ชื่อ lock (instance ที่ใช้ชื่อเดียวกันจะไม่ทำงานซ้อนกัน)
+	LockTimeout time.Duration // เวลาที่รอ lock ก่อนข้ามรอบนี้ (0 = ไม่รอ)
+
+	// เวลาที่รองานที่ค้างอยู่หลังได้รับ SIGINT/SIGTERM ก่อนยกเลิกงาน (rollback) และปิดโปรแกรม
+	ShutdownTimeout time.Duration
+}
+
+// LoadConfig กำหนดวิธีบันทึกข้อมูลแต่ละประเภท
+// append = insert ทุกแถว, upsert = MERGE ตาม natural key, replace = ลบแถวเดิมของ DocNo แล้ว insert ใหม่
+type LoadConfig struct {
+	HeaderMode string // natural key คือ DocNo
+	ItemMode   string // natural key คือ DocNo+ItemID
+	BatchSize  int    // จำนวนแถวที่อ่านแล้วบันทึกต่อครั้ง ทั้งไฟล์ยังอยู่ใน transaction เดียว
+
+	// ใช้ bulk copy ของ SQL Server แทน INSERT (ใช้ได้กับ append และ replace เท่านั้น)
+	HeaderBulkCopy bool
+	ItemBulkCopy   bool
+}
+
+// ValidationConfig กำหนดการตรวจสอบข้อมูลรายแถว
+type ValidationConfig struct {
+	RejectPolicy    string   // fail = ถ้ามีแถวไม่ผ่านให้ทั้งไฟล์ล้มเหลว, skip = บันทึกเฉพาะแถวที่ผ่าน
+	RejectDir       string   // โฟลเดอร์สำหรับไฟล์ <file>.rejects.csv (ว่าง = โฟลเดอร์เดียวกับไฟล์ที่ดาวน์โหลด)
+	AllowedStatuses []string // ค่า Status ที่อนุญาตในไฟล์ header (ว่าง = ไม่จำกัด)
+}
+
+// ColumnsConfig กำหนดการจับคู่คอลัมน์ในไฟล์ CSV ตามชื่อ header
+type ColumnsConfig struct {
+	Aliases    map[string]string // ชื่อคอลัมน์ในไฟล์ -> ชื่อคอลัมน์มาตรฐาน เช่น IO_No: IO_Number
+	AllowExtra bool              // อนุญาตให้มีคอลัมน์ที่ไม่รู้จัก (จะถูกข้ามไป)
 }
 
-func GetConfig() *Config {
+// SecretsConfig ตั้งค่าการเชื่อมต่อกับแหล่งเก็บ secret
+type SecretsConfig struct {
+	VaultAddress string // ถ้าไม่ระบุจะใช้ VAULT_ADDR
+	VaultToken   string // ถ้าไม่ระบุจะใช้ VAULT_TOKEN (รองรับ file: และ env:)
+}
+
+// GetConfig โหลดการตั้งค่าจากไฟล์ (path จาก argument หรือ MCMC_CONFIG)
+// แล้ว override ด้วย environment variable ที่ขึ้นต้นด้วย MCMC_
+// ถ้ามีฟิลด์ที่ขาดหายหรือไม่ถูกต้องจะคืน error ที่รวมทุกปัญหาไว้
+func GetConfig(path string) (*Config, error) {
+	return Load(path)
+}
+
+// Default คืนค่าเริ่มต้นของการตั้งค่าที่ไม่ใช่ข้อมูลเฉพาะของแต่ละ environment
+func Default() *Config {
 	return &Config{
 		Database: DatabaseConfig{
-			Driver:   "sqlserver",
-			Host:     "SLEAPOTCDEVST01.thaibev.com",
-			Port:     "1433",
-			User:     "mcmc_user",
-			Password: "mcmc$3rM1ddleware",
-			DBName:   "MCMC_Middleware",
+			Driver:          "sqlserver",
+			AppName:         "mcmc",
+			Encrypt:         "disable",
+			Authentication:  "sql",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+			AutoMigrate:     true,
 		},
 		SFTP: SFTPConfig{
-			Host:       "10.7.57.119",
-			Port:       "22",
-			User:       "mcmcvendoruser",
-			Password:   "KPHaE8dmZA0baWzU",
-			RemotePath: "VSMSUAT/back",
+			Port:            "22",
+			ChecksumSidecar: "optional",
 		},
 		App: AppConfig{
 			DownloadDir: "./downloaded_files",
@@ -62,10 +201,275 @@ func GetConfig() *Config {
 				"saleorder_header_",
 			},
 		},
+		Archive: ArchiveConfig{
+			RemoteOnSuccess:  "keep",
+			RemoteOnFailure:  "keep",
+			RemoteSuccessDir: "archive",
+			RemoteFailureDir: "error",
+			LocalRetention:   30 * 24 * time.Hour,
+		},
 		Cron: CronConfig{
-			Schedule:      "*/5 * * * *", 
-			RunOnce:       false,          
-			RetryInterval: 5 * time.Minute, 
+			Schedule:        "*/5 * * * *",
+			RunOnce:         false,
+			RetryInterval:   5 * time.Minute,
+			RetryAttempts:   5,
+			RetryBaseDelay:  2 * time.Second,
+			LockName:        "mcmc-run",
+			ShutdownTimeout: 30 * time.Second,
 		},
+		Load: LoadConfig{
+			HeaderMode: "append",
+			ItemMode:   "append",
+			BatchSize:  1000,
+		},
+		Validation: ValidationConfig{
+			RejectPolicy: "fail",
+		},
+	}
+}
+
+// Validate ตรวจสอบการตั้งค่าทั้งหมดและคืนรายการปัญหาที่พบ
+func (c *Config) Validate() error {
+	var problems []string
+	required := func(name, value string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, fmt.Sprintf("%s: ต้องระบุค่า", name))
+		}
+	}
+	port := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 || n > 65535 {
+			problems = append(problems, fmt.Sprintf("%s: port ไม่ถูกต้อง (%q)", name, value))
+		}
+	}
+
+	switch c.Database.Driver {
+	case "sqlserver":
+		required("database.host", c.Database.Host)
+		port("database.port", c.Database.Port)
+		problems = append(problems, validateSQLServer(c.Database)...)
+	case "postgres", "mysql":
+		required("database.host", c.Database.Host)
+		port("database.port", c.Database.Port)
+		required("database.user", c.Database.User)
+		required("database.password", c.Database.Password)
+	case "sqlite":
+	default:
+		problems = append(problems, fmt.Sprintf("database.driver: ไม่รู้จักฐานข้อมูลชนิด %q (รองรับ sqlserver, postgres, mysql, sqlite)", c.Database.Driver))
+	}
+	if c.Database.Driver != "sqlserver" {
+		sqlServerOnly := []struct {
+			name string
+			set  bool
+		}{
+			{"database.encrypt", c.Database.Encrypt != "" && c.Database.Encrypt != "disable"},
+			{"database.ca_file", c.Database.CAFile != ""},
+			{"database.server_cert_hostname", c.Database.ServerCertHostname != ""},
+			{"database.trust_server_certificate", c.Database.TrustServerCertificate},
+			{"database.instance", c.Database.Instance != ""},
+			{"database.authentication", c.Database.Authentication != "" && c.Database.Authentication != "sql"},
+		}
+		for _, option := range sqlServerOnly {
+			if option.set {
+				problems = append(problems, fmt.Sprintf("%s: ใช้ได้กับ database.driver sqlserver เท่านั้น", option.name))
+			}
+		}
+	}
+	required("database.db_name", c.Database.DBName)
+	if c.Database.ConnectTimeout < 0 {
+		problems = append(problems, "database.connect_timeout: ต้องไม่ติดลบ")
+	}
+	if c.Database.MaxOpenConns < 0 {
+		problems = append(problems, "database.max_open_conns: ต้องไม่ติดลบ")
 	}
-}
\ No newline at end of file
+	if c.Database.MaxIdleConns < 0 {
+		problems = append(problems, "database.max_idle_conns: ต้องไม่ติดลบ")
+	} else if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		problems = append(problems, "database.max_idle_conns: ต้องไม่เกิน database.max_open_conns")
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		problems = append(problems, "database.conn_max_lifetime: ต้องไม่ติดลบ")
+	}
+	if c.Database.ConnMaxIdleTime < 0 {
+		problems = append(problems, "database.conn_max_idle_time: ต้องไม่ติดลบ")
+	}
+
+	required("sftp.host", c.SFTP.Host)
+	required("sftp.port", c.SFTP.Port)
+	port("sftp.port", c.SFTP.Port)
+	required("sftp.user", c.SFTP.User)
+	required("sftp.remote_path", c.SFTP.RemotePath)
+	if !c.SFTP.InsecureIgnoreHostKey && c.SFTP.KnownHostsFile == "" && len(c.SFTP.HostKeyFingerprints) == 0 {
+		problems = append(problems, "sftp.known_hosts_file: ต้องกำหนด known_hosts_file หรือ host_key_fingerprints (หรือ insecure_ignore_host_key สำหรับทดสอบ)")
+	}
+	authMethods := c.SFTP.EffectiveAuthMethods()
+	if len(authMethods) == 0 {
+		problems = append(problems, "sftp.auth_methods: ต้องกำหนด password, private_key_file หรือ auth_methods อย่างน้อยหนึ่งแบบ")
+	}
+	for i, method := range authMethods {
+		switch method {
+		case "publickey":
+			required("sftp.private_key_file", c.SFTP.PrivateKeyFile)
+		case "password":
+			required("sftp.password", c.SFTP.Password)
+		case "agent":
+		default:
+			problems = append(problems, fmt.Sprintf("sftp.auth_methods[%d]: ไม่รู้จักวิธียืนยันตัวตน %q (รองรับ publickey, agent, password)", i, method))
+		}
+	}
+
+	switch c.SFTP.ChecksumSidecar {
+	case "none", "optional", "required":
+	default:
+		problems = append(problems, fmt.Sprintf("sftp.checksum_sidecar: ไม่รู้จักค่า %q (รองรับ none, optional, required)", c.SFTP.ChecksumSidecar))
+	}
+
+	required("app.download_dir", c.App.DownloadDir)
+	if len(c.App.FileTypes) == 0 {
+		problems = append(problems, "app.file_types: ต้องระบุอย่างน้อย 1 prefix")
+	}
+	for i, prefix := range c.App.FileTypes {
+		if strings.TrimSpace(prefix) == "" {
+			problems = append(problems, fmt.Sprintf("app.file_types[%d]: prefix ว่าง", i))
+		}
+	}
+
+	remoteAction := func(name, action, dirName, dir string) {
+		switch action {
+		case "keep", "delete":
+		case "move":
+			required(dirName, dir)
+		default:
+			problems = append(problems, fmt.Sprintf("%s: ไม่รู้จักการจัดการไฟล์ %q (รองรับ keep, move, delete)", name, action))
+		}
+	}
+	remoteAction("archive.remote_on_success", c.Archive.RemoteOnSuccess, "archive.remote_success_dir", c.Archive.RemoteSuccessDir)
+	remoteAction("archive.remote_on_failure", c.Archive.RemoteOnFailure, "archive.remote_failure_dir", c.Archive.RemoteFailureDir)
+	if c.Archive.LocalRetention < 0 {
+		problems = append(problems, "archive.local_retention: ต้องไม่ติดลบ")
+	}
+
+	if !c.Cron.RunOnce {
+		required("cron.schedule", c.Cron.Schedule)
+		if c.Cron.Schedule != "" {
+			if _, err := cron.ParseStandard(c.Cron.Schedule); err != nil {
+				problems = append(problems, fmt.Sprintf("cron.schedule: cron expression ไม่ถูกต้อง: %v", err))
+			}
+		}
+	}
+	if c.Cron.RetryInterval <= 0 {
+		problems = append(problems, "cron.retry_interval: ต้องมากกว่า 0")
+	}
+	if c.Cron.RetryAttempts < 1 {
+		problems = append(problems, "cron.retry_attempts: ต้องมากกว่าหรือเท่ากับ 1")
+	}
+	if c.Cron.RetryBaseDelay <= 0 {
+		problems = append(problems, "cron.retry_base_delay: ต้องมากกว่า 0")
+	} else if c.Cron.RetryBaseDelay > c.Cron.RetryInterval {
+		problems = append(problems, "cron.retry_base_delay: ต้องไม่เกิน cron.retry_interval")
+	}
+	required("cron.lock_name", c.Cron.LockName)
+	if c.Cron.ShutdownTimeout <= 0 {
+		problems = append(problems, "cron.shutdown_timeout: ต้องมากกว่า 0")
+	}
+	if c.Cron.LockTimeout < 0 {
+		problems = append(problems, "cron.lock_timeout: ต้องไม่ติดลบ")
+	}
+
+	loadMode := func(name, value string) {
+		switch value {
+		case "append", "upsert", "replace":
+		default:
+			problems = append(problems, fmt.Sprintf("%s: ไม่รู้จักวิธีบันทึกข้อมูล %q (รองรับ append, upsert, replace)", name, value))
+		}
+	}
+	loadMode("load.header_mode", c.Load.HeaderMode)
+	loadMode("load.item_mode", c.Load.ItemMode)
+	bulkCopy := func(name string, enabled bool, mode string) {
+		if enabled && mode == "upsert" {
+			problems = append(problems, fmt.Sprintf("%s: ใช้ร่วมกับ upsert ไม่ได้ (รองรับ append, replace)", name))
+		}
+		if enabled && c.Database.Driver != "sqlserver" {
+			problems = append(problems, fmt.Sprintf("%s: ใช้ได้กับ database.driver sqlserver เท่านั้น", name))
+		}
+	}
+	bulkCopy("load.header_bulk_copy", c.Load.HeaderBulkCopy, c.Load.HeaderMode)
+	bulkCopy("load.item_bulk_copy", c.Load.ItemBulkCopy, c.Load.ItemMode)
+	if c.Load.BatchSize <= 0 {
+		problems = append(problems, "load.batch_size: ต้องมากกว่า 0")
+	}
+
+	switch c.Validation.RejectPolicy {
+	case "fail", "skip":
+	default:
+		problems = append(problems, fmt.Sprintf("validation.reject_policy: ไม่รู้จักนโยบาย %q (รองรับ fail, skip)", c.Validation.RejectPolicy))
+	}
+
+	problems = append(problems, validateFeeds(c.Feeds, c.App.FileTypes)...)
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateSQLServer ตรวจสอบการตั้งค่า TLS และการยืนยันตัวตนของ SQL Server
+func validateSQLServer(db DatabaseConfig) []string {
+	var problems []string
+	required := func(name, value string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, fmt.Sprintf("%s: ต้องระบุค่า", name))
+		}
+	}
+
+	switch db.Encrypt {
+	case "disable":
+		if db.CAFile != "" || db.ServerCertHostname != "" || db.TrustServerCertificate {
+			problems = append(problems, "database.encrypt: ต้องเป็น true หรือ strict เมื่อกำหนด ca_file, server_cert_hostname หรือ trust_server_certificate")
+		}
+	case "true", "strict":
+		if db.TrustServerCertificate && db.CAFile != "" {
+			problems = append(problems, "database.trust_server_certificate: ใช้ร่วมกับ database.ca_file ไม่ได้")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("database.encrypt: ไม่รู้จักค่า %q (รองรับ disable, true, strict)", db.Encrypt))
+	}
+
+	switch db.Authentication {
+	case "sql":
+		required("database.user", db.User)
+		required("database.password", db.Password)
+	case "ntlm":
+		required("database.password", db.Password)
+		if !strings.Contains(db.User, `\`) {
+			problems = append(problems, `database.user: ต้องอยู่ในรูปแบบ DOMAIN\user เมื่อใช้ ntlm`)
+		}
+	case "azure_ad":
+		switch db.FedAuth {
+		case "ActiveDirectoryPassword":
+			required("database.user", db.User)
+			required("database.password", db.Password)
+		case "ActiveDirectoryServicePrincipal":
+			// user คือ client ID ในรูปแบบ <client_id>@<tenant_id> และ password คือ client secret
+			required("database.user", db.User)
+			required("database.password", db.Password)
+		case "ActiveDirectoryDefault", "ActiveDirectoryManagedIdentity", "ActiveDirectoryAzCli":
+		default:
+			problems = append(problems, fmt.Sprintf("database.fed_auth: ไม่รู้จักวิธี %q (รองรับ ActiveDirectoryDefault, ActiveDirectoryPassword, ActiveDirectoryServicePrincipal, ActiveDirectoryManagedIdentity, ActiveDirectoryAzCli)", db.FedAuth))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("database.authentication: ไม่รู้จักวิธียืนยันตัวตน %q (รองรับ sql, ntlm, azure_ad)", db.Authentication))
+	}
+	return problems
+}
+
+// ValidationError รวมปัญหาทั้งหมดที่พบระหว่างโหลดและตรวจสอบการตั้งค่า
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("การตั้งค่าไม่ถูกต้อง %d รายการ:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
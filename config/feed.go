@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// FeedSchema อธิบายไฟล์ของ vendor หนึ่งประเภทที่บันทึกลงตารางด้วย loader ทั่วไป
+// (ไม่ต้องเขียน model หรือฟังก์ชันอ่านไฟล์ใหม่) เช่น saleorder_payment_, customer_master_
+type FeedSchema struct {
+	Name       string   // ชื่อ feed ใช้แสดงใน log และบันทึกเป็นประเภทไฟล์
+	Pattern    string   // รูปแบบชื่อไฟล์ เช่น saleorder_payment_*.csv
+	Delimiter  string   // ตัวคั่นคอลัมน์ (ว่าง = |)
+	Table      string   // ตารางปลายทาง (จะถูกสร้างให้ถ้ายังไม่มี)
+	NaturalKey []string // ชื่อคอลัมน์ปลายทางที่ใช้ระบุแถว (จำเป็นสำหรับ upsert และ replace)
+	LoadMode   string   // append, upsert, replace (ว่าง = append)
+	Columns    []FeedColumn
+}
+
+// FeedColumn อธิบายคอลัมน์หนึ่งในไฟล์ของ feed
+type FeedColumn struct {
+	Name      string // ชื่อคอลัมน์ใน header ของไฟล์
+	Target    string // ชื่อคอลัมน์ในตารางปลายทาง (ว่าง = snake_case ของ Name)
+	Type      string // text, number, integer, date (ว่าง = text)
+	Required  bool
+	MaxLength int      // ความยาวสูงสุดของ text (0 = ไม่จำกัด)
+	Allowed   []string // ค่าที่อนุญาต (ว่าง = ไม่จำกัด)
+}
+
+// Prefix คืนส่วนต้นของ Pattern ก่อนอักขระ wildcard ตัวแรก ใช้ค้นหาประวัติการประมวลผลไฟล์
+func (f FeedSchema) Prefix() string {
+	if i := strings.IndexAny(f.Pattern, "*?[\\"); i >= 0 {
+		return f.Pattern[:i]
+	}
+	return f.Pattern
+}
+
+// Match ตรวจสอบว่าชื่อไฟล์ตรงกับ Pattern ของ feed หรือไม่
+func (f FeedSchema) Match(fileName string) bool {
+	ok, err := path.Match(f.Pattern, fileName)
+	return err == nil && ok
+}
+
+// TargetName คืนชื่อคอลัมน์ปลายทางของคอลัมน์
+func (c FeedColumn) TargetName() string {
+	if c.Target != "" {
+		return c.Target
+	}
+	return snakeCase(c.Name)
+}
+
+// LoadSchemas อ่านไฟล์ schema ของ feed ทุกไฟล์ (.yaml, .yml, .toml, .json) ในโฟลเดอร์ dir
+// แต่ละไฟล์อธิบาย feed หนึ่งรายการ โดยใช้ชื่อ key แบบ snake_case เช่นเดียวกับไฟล์ config
+func LoadSchemas(dir string) ([]FeedSchema, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่านโฟลเดอร์ schema %s ได้: %v", dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".toml", ".json":
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var schemas []FeedSchema
+	var problems []string
+	for _, name := range names {
+		values, err := readFile(filepath.Join(dir, name))
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		var schema FeedSchema
+		applyMap(reflect.ValueOf(&schema).Elem(), values, name, &problems)
+		schemas = append(schemas, schema)
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return schemas, nil
+}
+
+// validateFeeds ตรวจสอบ schema ของทุก feed และคืนรายการปัญหาที่พบ
+func validateFeeds(feeds []FeedSchema, fileTypes []string) []string {
+	var problems []string
+	names := map[string]bool{}
+	tables := map[string]string{}
+
+	for i, feed := range feeds {
+		name := fmt.Sprintf("feeds[%d]", i)
+		if feed.Name != "" {
+			name = fmt.Sprintf("feeds[%s]", feed.Name)
+		}
+		add := func(format string, args ...interface{}) {
+			problems = append(problems, name+"."+fmt.Sprintf(format, args...))
+		}
+
+		if strings.TrimSpace(feed.Name) == "" {
+			add("name: ต้องระบุค่า")
+		} else if names[feed.Name] {
+			add("name: ชื่อ feed ซ้ำ")
+		}
+		names[feed.Name] = true
+
+		if strings.TrimSpace(feed.Pattern) == "" {
+			add("pattern: ต้องระบุค่า")
+		} else if _, err := path.Match(feed.Pattern, ""); err != nil {
+			add("pattern: รูปแบบไม่ถูกต้อง (%q)", feed.Pattern)
+		}
+		for _, prefix := range fileTypes {
+			if feed.Prefix() != "" && strings.HasPrefix(feed.Prefix(), prefix) {
+				add("pattern: ซ้ำกับ app.file_types %q", prefix)
+			}
+		}
+
+		if feed.Delimiter != "" && utf8.RuneCountInString(feed.Delimiter) != 1 {
+			add("delimiter: ต้องเป็นอักขระเดียว (%q)", feed.Delimiter)
+		}
+
+		if strings.TrimSpace(feed.Table) == "" {
+			add("table: ต้องระบุค่า")
+		} else if other, ok := tables[strings.ToLower(feed.Table)]; ok {
+			add("table: ใช้ตารางเดียวกับ feed %s", other)
+		} else {
+			tables[strings.ToLower(feed.Table)] = feed.Name
+		}
+
+		switch feed.LoadMode {
+		case "", "append":
+		case "upsert", "replace":
+			if len(feed.NaturalKey) == 0 {
+				add("natural_key: ต้องระบุเมื่อใช้ load_mode %s", feed.LoadMode)
+			}
+		default:
+			add("load_mode: ไม่รู้จักวิธีบันทึกข้อมูล %q (รองรับ append, upsert, replace)", feed.LoadMode)
+		}
+
+		if len(feed.Columns) == 0 {
+			add("columns: ต้องระบุอย่างน้อย 1 คอลัมน์")
+		}
+		targets := map[string]bool{}
+		for j, column := range feed.Columns {
+			if strings.TrimSpace(column.Name) == "" {
+				add("columns[%d].name: ต้องระบุค่า", j)
+				continue
+			}
+			target := strings.ToLower(column.TargetName())
+			switch target {
+			case "id", "source_file", "created_at":
+				add("columns[%d].target: %s เป็นชื่อคอลัมน์ที่สงวนไว้", j, column.TargetName())
+			}
+			if targets[target] {
+				add("columns[%d].target: ชื่อคอลัมน์ปลายทางซ้ำ (%s)", j, column.TargetName())
+			}
+			targets[target] = true
+
+			switch column.Type {
+			case "", "text", "number", "integer", "date":
+			default:
+				add("columns[%d].type: ไม่รู้จักชนิดข้อมูล %q (รองรับ text, number, integer, date)", j, column.Type)
+			}
+			if column.MaxLength < 0 {
+				add("columns[%d].max_length: ต้องไม่ติดลบ", j)
+			}
+		}
+		for _, key := range feed.NaturalKey {
+			if !targets[strings.ToLower(key)] {
+				add("natural_key: ไม่พบคอลัมน์ %s ใน columns", key)
+			}
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,387 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load โหลดการตั้งค่าโดยเริ่มจากค่าเริ่มต้น ทับด้วยค่าจากไฟล์ (ถ้ามี)
+// แล้วทับด้วย environment variable จากนั้นตรวจสอบความถูกต้องของทุกฟิลด์
+//
+// ชื่อ key ในไฟล์ใช้รูปแบบ snake_case ของชื่อฟิลด์ (เช่น download_dir, db_name)
+// ส่วน environment variable ใช้รูปแบบ MCMC_<SECTION>_<FIELD> (เช่น MCMC_DATABASE_DB_NAME)
+// ฟิลด์ที่เป็น list หรือ map รับค่าจาก environment แบบคั่นด้วยคอมมา (a,b หรือ k=v,k2=v2)
+func Load(path string) (*Config, error) {
+	return LoadWithOverrides(path, nil)
+}
+
+// LoadWithOverrides โหลดการตั้งค่าเช่นเดียวกับ Load แล้วทับด้วย overrides เป็นลำดับสุดท้าย (ก่อนตรวจสอบ)
+// key ของ overrides ใช้ชื่อเดียวกับในไฟล์คั่นด้วยจุด เช่น cron.schedule หรือ app.download_dir
+func LoadWithOverrides(path string, overrides map[string]string) (*Config, error) {
+	cfg := Default()
+	var problems []string
+
+	if path == "" {
+		path = os.Getenv(ConfigPathEnv)
+	}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		applyMap(reflect.ValueOf(cfg).Elem(), values, "", &problems)
+	}
+
+	applyEnv(reflect.ValueOf(cfg).Elem(), EnvPrefix, "", &problems)
+	applyMap(reflect.ValueOf(cfg).Elem(), nestOverrides(overrides), "", &problems)
+
+	if cfg.App.SchemaDir != "" {
+		feeds, err := LoadSchemas(cfg.App.SchemaDir)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Feeds = append(cfg.Feeds, feeds...)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		if verr, ok := err.(*ValidationError); ok {
+			problems = append(problems, verr.Problems...)
+		} else {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// readFile อ่านไฟล์ config ตามนามสกุล (.yaml, .yml, .toml, .json)
+func readFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่านไฟล์ config %s ได้: %v", path, err)
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("ไม่รู้จักรูปแบบไฟล์ config: %s (รองรับ .yaml, .yml, .toml, .json)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถแปลงไฟล์ config %s ได้: %v", path, err)
+	}
+
+	return values, nil
+}
+
+// nestOverrides แปลง key แบบคั่นด้วยจุดเป็น map ซ้อนกันรูปแบบเดียวกับที่อ่านจากไฟล์
+func nestOverrides(overrides map[string]string) map[string]interface{} {
+	root := map[string]interface{}{}
+	for key, value := range overrides {
+		parts := strings.Split(key, ".")
+		m := root
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := m[part].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				m[part] = next
+			}
+			m = next
+		}
+		m[parts[len(parts)-1]] = value
+	}
+	return root
+}
+
+// applyMap กำหนดค่าจาก map ที่อ่านจากไฟล์ลงใน struct
+func applyMap(v reflect.Value, values map[string]interface{}, prefix string, problems *[]string) {
+	fields := map[string]int{}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fields[normalizeKey(fieldKey(f))] = i
+	}
+
+	for key, raw := range values {
+		name := joinPath(prefix, key)
+		idx, ok := fields[normalizeKey(key)]
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: ไม่รู้จักฟิลด์นี้", name))
+			continue
+		}
+		setValue(v.Field(idx), raw, joinPath(prefix, fieldKey(v.Type().Field(idx))), problems)
+	}
+}
+
+// setValue กำหนดค่าจากไฟล์ลงในฟิลด์ตามชนิดของฟิลด์
+func setValue(fv reflect.Value, raw interface{}, name string, problems *[]string) {
+	if raw == nil {
+		return
+	}
+
+	switch {
+	case fv.Kind() == reflect.Struct:
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: ต้องเป็น object", name))
+			return
+		}
+		applyMap(fv, m, name, problems)
+
+	case fv.Kind() == reflect.Ptr:
+		ptr := reflect.New(fv.Type().Elem())
+		setValue(ptr.Elem(), raw, name, problems)
+		fv.Set(ptr)
+
+	case fv.Kind() == reflect.Slice:
+		list, ok := raw.([]interface{})
+		if !ok {
+			if s, isString := raw.(string); isString && fv.Type().Elem().Kind() != reflect.Struct {
+				setFromString(fv, s, name, problems)
+				return
+			}
+			*problems = append(*problems, fmt.Sprintf("%s: ต้องเป็น list", name))
+			return
+		}
+		slice := reflect.MakeSlice(fv.Type(), len(list), len(list))
+		for i, item := range list {
+			setValue(slice.Index(i), item, fmt.Sprintf("%s[%d]", name, i), problems)
+		}
+		fv.Set(slice)
+
+	case fv.Kind() == reflect.Map:
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: ต้องเป็น object", name))
+			return
+		}
+		if fv.IsNil() {
+			fv.Set(reflect.MakeMap(fv.Type()))
+		}
+		for k, item := range m {
+			elem := reflect.New(fv.Type().Elem()).Elem()
+			setValue(elem, item, joinPath(name, k), problems)
+			fv.SetMapIndex(reflect.ValueOf(k), elem)
+		}
+
+	default:
+		s, ok := scalarString(raw)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: ต้องเป็นค่าเดี่ยว", name))
+			return
+		}
+		if err := setScalar(fv, s); err != nil {
+			*problems = append(*problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+}
+
+// applyEnv ทับค่าใน struct ด้วย environment variable ที่ตรงกับชื่อฟิลด์
+func applyEnv(v reflect.Value, envPrefix, prefix string, problems *[]string) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key := fieldKey(f)
+		envName := envPrefix + "_" + strings.ToUpper(key)
+		name := joinPath(prefix, key)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			applyEnv(fv, envName, name, problems)
+			continue
+		}
+
+		raw, ok := os.LookupEnv(envName)
+		if !ok {
+			continue
+		}
+		setFromString(fv, raw, envName, problems)
+	}
+}
+
+// setFromString กำหนดค่าจากข้อความ (ใช้กับ environment variable และ list ในรูปข้อความ)
+func setFromString(fv reflect.Value, raw, name string, problems *[]string) {
+	switch fv.Kind() {
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Struct {
+			*problems = append(*problems, fmt.Sprintf("%s: ไม่รองรับการกำหนดค่าจากข้อความ", name))
+			return
+		}
+		parts := splitList(raw)
+		slice := reflect.MakeSlice(fv.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setScalar(slice.Index(i), part); err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s[%d]: %v", name, i, err))
+			}
+		}
+		fv.Set(slice)
+
+	case reflect.Map:
+		if fv.Type().Elem().Kind() == reflect.Struct {
+			*problems = append(*problems, fmt.Sprintf("%s: ไม่รองรับการกำหนดค่าจากข้อความ", name))
+			return
+		}
+		m := reflect.MakeMap(fv.Type())
+		for _, part := range splitList(raw) {
+			k, val, ok := strings.Cut(part, "=")
+			if !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: ต้องอยู่ในรูปแบบ key=value (%q)", name, part))
+				continue
+			}
+			elem := reflect.New(fv.Type().Elem()).Elem()
+			if err := setScalar(elem, strings.TrimSpace(val)); err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s.%s: %v", name, k, err))
+				continue
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(k)), elem)
+		}
+		fv.Set(m)
+
+	case reflect.Ptr:
+		ptr := reflect.New(fv.Type().Elem())
+		setFromString(ptr.Elem(), raw, name, problems)
+		fv.Set(ptr)
+
+	default:
+		if err := setScalar(fv, raw); err != nil {
+			*problems = append(*problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+}
+
+// setScalar แปลงข้อความเป็นค่าตามชนิดของฟิลด์
+func setScalar(fv reflect.Value, s string) error {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("duration ไม่ถูกต้อง (%q) ต้องมีหน่วย เช่น 30s, 5m", s)
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("ต้องเป็น true หรือ false (%q)", s)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("ต้องเป็นจำนวนเต็ม (%q)", s)
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(strings.TrimSpace(s), 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("ต้องเป็นจำนวนเต็มบวก (%q)", s)
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(strings.TrimSpace(s), fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("ต้องเป็นตัวเลข (%q)", s)
+		}
+		fv.SetFloat(n)
+	default:
+		return fmt.Errorf("ไม่รองรับชนิดข้อมูล %s", fv.Type())
+	}
+	return nil
+}
+
+// scalarString แปลงค่าเดี่ยวจาก YAML/TOML/JSON เป็นข้อความ
+func scalarString(raw interface{}) (string, bool) {
+	switch v := raw.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// fieldKey คืนชื่อ key ของฟิลด์ (จาก tag `config` หรือแปลงชื่อฟิลด์เป็น snake_case)
+func fieldKey(f reflect.StructField) string {
+	if tag := f.Tag.Get("config"); tag != "" {
+		return tag
+	}
+	return snakeCase(f.Name)
+}
+
+// snakeCase แปลงชื่อแบบ CamelCase เป็น snake_case โดยคงตัวย่อไว้ด้วยกัน (DBName -> db_name)
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// normalizeKey ทำให้ key เทียบกันได้โดยไม่สนตัวพิมพ์และเครื่องหมาย _ หรือ -
+func normalizeKey(key string) string {
+	key = strings.ToLower(key)
+	key = strings.ReplaceAll(key, "_", "")
+	return strings.ReplaceAll(key, "-", "")
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func splitList(raw string) []string {
+	var parts []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testConfig คือไฟล์ config ขั้นต่ำที่ผ่านการตรวจสอบ
+const testConfig = `database:
+  host: db.local
+  user: mcmc
+  password: secret
+  db_name: mcmc
+sftp:
+  host: 127.0.0.1
+  user: mcmc
+  password: secret
+  remote_path: /in
+  insecure_ignore_host_key: true
+cron:
+  schedule: "0 * * * *"
+  retry_interval: 30s
+`
+
+// writeConfig เขียน content ลงไฟล์ name ในโฟลเดอร์ชั่วคราวของ test และคืน path
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("MCMC_SFTP_PORT", "2222")
+	t.Setenv("MCMC_APP_FILE_TYPES", "a_,b_")
+
+	cfg, err := Load(writeConfig(t, "config.yaml", testConfig))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Database.Host != "db.local" || cfg.Database.DBName != "mcmc" {
+		t.Errorf("database = %+v ต้องการค่าจากไฟล์", cfg.Database)
+	}
+	if cfg.Database.Driver != "sqlserver" {
+		t.Errorf("database.driver = %q ต้องการค่าเริ่มต้น sqlserver", cfg.Database.Driver)
+	}
+	if cfg.SFTP.Port != "2222" {
+		t.Errorf("sftp.port = %q ต้องการค่าจาก environment", cfg.SFTP.Port)
+	}
+	if want := []string{"a_", "b_"}; !reflect.DeepEqual(cfg.App.FileTypes, want) {
+		t.Errorf("app.file_types = %v ต้องการ %v", cfg.App.FileTypes, want)
+	}
+	if cfg.Cron.Schedule != "0 * * * *" || cfg.Cron.RetryInterval != 30*time.Second {
+		t.Errorf("cron = %+v ต้องการค่าจากไฟล์", cfg.Cron)
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	files := map[string]string{
+		"config.toml": "[database]\nhost = \"db.local\"\n",
+		"config.json": `{"database": {"host": "db.local"}}`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			values, err := readFile(writeConfig(t, name, content))
+			if err != nil {
+				t.Fatalf("readFile: %v", err)
+			}
+			database, ok := values["database"].(map[string]interface{})
+			if !ok || database["host"] != "db.local" {
+				t.Errorf("readFile = %v ต้องการ database.host = db.local", values)
+			}
+		})
+	}
+
+	if _, err := readFile(writeConfig(t, "config.ini", "")); err == nil {
+		t.Errorf("readFile ต้องคืน error เมื่อไม่รู้จักนามสกุลไฟล์")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		problem string
+	}{
+		{
+			name:    "ไม่รู้จักฟิลด์",
+			content: testConfig + "app:\n  unknown: 1\n",
+			problem: "app.unknown",
+		},
+		{
+			name:    "ขาดค่าที่จำเป็น",
+			content: strings.Replace(testConfig, "  db_name: mcmc\n", "", 1),
+			problem: "database.db_name",
+		},
+		{
+			name:    "ไม่ได้กำหนดการตรวจสอบ host key",
+			content: strings.Replace(testConfig, "  insecure_ignore_host_key: true\n", "", 1),
+			problem: "sftp.known_hosts_file",
+		},
+		{
+			name:    "port ไม่ถูกต้องจาก environment",
+			content: testConfig,
+			env:     map[string]string{"MCMC_DATABASE_PORT": "abc"},
+			problem: "database.port",
+		},
+		{
+			name:    "duration ไม่ถูกต้อง",
+			content: strings.Replace(testConfig, "retry_interval: 30s", "retry_interval: soon", 1),
+			problem: "cron.retry_interval",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := Load(writeConfig(t, "config.yaml", tt.content))
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("Load error = %v ต้องการ *ValidationError", err)
+			}
+			found := false
+			for _, problem := range verr.Problems {
+				if strings.HasPrefix(problem, tt.problem) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("ไม่พบปัญหาของ %s ใน %v", tt.problem, verr.Problems)
+			}
+		})
+	}
+}
+
+func TestNestOverrides(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		want      map[string]interface{}
+	}{
+		{
+			name:      "ว่าง",
+			overrides: nil,
+			want:      map[string]interface{}{},
+		},
+		{
+			name:      "key ชั้นเดียว",
+			overrides: map[string]string{"feeds": "x"},
+			want:      map[string]interface{}{"feeds": "x"},
+		},
+		{
+			name:      "section เดียวกันรวมเป็น map เดียว",
+			overrides: map[string]string{"cron.schedule": "@hourly", "cron.run_once": "true"},
+			want: map[string]interface{}{
+				"cron": map[string]interface{}{"schedule": "@hourly", "run_once": "true"},
+			},
+		},
+		{
+			name:      "ซ้อนหลายชั้น",
+			overrides: map[string]string{"a.b.c": "1", "a.d": "2", "sftp.port": "2222"},
+			want: map[string]interface{}{
+				"a":    map[string]interface{}{"b": map[string]interface{}{"c": "1"}, "d": "2"},
+				"sftp": map[string]interface{}{"port": "2222"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nestOverrides(tt.overrides); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nestOverrides(%v) = %v ต้องการ %v", tt.overrides, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadWithOverrides(t *testing.T) {
+	path := writeConfig(t, "config.yaml", testConfig)
+
+	cfg, err := LoadWithOverrides(path, map[string]string{"cron.schedule": "@every 5m", "cron.run_once": "true"})
+	if err != nil {
+		t.Fatalf("LoadWithOverrides: %v", err)
+	}
+	if cfg.Cron.Schedule != "@every 5m" || !cfg.Cron.RunOnce {
+		t.Errorf("cron = %+v ต้องการค่าจาก overrides", cfg.Cron)
+	}
+	if cfg.Database.DBName != "mcmc" {
+		t.Errorf("database.db_name = %q ต้องการค่าจากไฟล์", cfg.Database.DBName)
+	}
+
+	if _, err := LoadWithOverrides(path, map[string]string{"cron.unknown": "1"}); err == nil {
+		t.Errorf("LoadWithOverrides ต้องคืน error เมื่อ key ไม่รู้จัก")
+	}
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"fmt"
+	"mcmc/models"
+	"time"
+)
+
+// สถานะของการประมวลผลไฟล์หนึ่งครั้ง
+const (
+	AttemptRunning = "running"
+	AttemptSuccess = "success"
+	AttemptFailed  = "failed"
+)
+
+// AttemptStats คือผลการประมวลผลไฟล์ที่บันทึกไว้กับ attempt
+type AttemptStats struct {
+	RowsRead     int
+	RowsWritten  int
+	RowsRejected int
+	Load         LoadResult
+}
+
+// AttemptLog บันทึกการประมวลผลไฟล์แต่ละครั้งของหนึ่งรอบการทำงาน (run) ลงตาราง file_processing_attempts
+// Start เพิ่มแถวสถานะ running ทันที ถ้าโปรเซสหยุดกลางคันจะเห็นได้ว่าไฟล์ใดค้างอยู่
+type AttemptLog struct {
+	db    *DB
+	runID string
+	host  string
+	open  map[string]*models.FileProcessingAttempt
+}
+
+// NewAttemptLog สร้าง AttemptLog ของรอบการทำงาน runID บนเครื่อง host
+// ควรใช้ DB ที่ไม่อยู่ใน transaction เพื่อให้ประวัติยังอยู่แม้ข้อมูลถูก rollback
+func (db *DB) NewAttemptLog(runID, host string) *AttemptLog {
+	return &AttemptLog{db: db, runID: runID, host: host, open: map[string]*models.FileProcessingAttempt{}}
+}
+
+// RunID คืน ID ของรอบการทำงาน
+func (l *AttemptLog) RunID() string {
+	return l.runID
+}
+
+// Start เริ่ม attempt ใหม่ของไฟล์ filename (ไม่ทำอะไรถ้าเริ่มไว้แล้วในรอบนี้)
+func (l *AttemptLog) Start(filename string) error {
+	if _, ok := l.open[filename]; ok {
+		return nil
+	}
+
+	var attempt int
+	query := "SELECT COALESCE(MAX(attempt), 0) + 1 FROM file_processing_attempts WHERE filename = ?"
+	if err := l.db.DB.Raw(query, filename).Scan(&attempt).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกประวัติการประมวลผลไฟล์ %s ได้: %v", filename, err)
+	}
+
+	record := &models.FileProcessingAttempt{
+		Filename:  filename,
+		Attempt:   attempt,
+		RunID:     l.runID,
+		Host:      l.host,
+		Status:    AttemptRunning,
+		StartedAt: time.Now(),
+	}
+	l.open[filename] = record
+	if err := l.db.Create(record).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกประวัติการประมวลผลไฟล์ %s ได้: %v", filename, err)
+	}
+	return nil
+}
+
+// AddBytes เพิ่มจำนวน byte ที่ดาวน์โหลดของไฟล์ filename ใน attempt ปัจจุบัน
+func (l *AttemptLog) AddBytes(filename string, n int64) {
+	if record, ok := l.open[filename]; ok {
+		record.Bytes += n
+	}
+}
+
+// SetSHA256 บันทึก SHA-256 ของไฟล์ filename ที่ดาวน์โหลดใน attempt ปัจจุบัน
+func (l *AttemptLog) SetSHA256(filename, sum string) {
+	if record, ok := l.open[filename]; ok {
+		record.SHA256 = sum
+	}
+}
+
+// Finish ปิด attempt ปัจจุบันของไฟล์ filename ด้วยสถานะ status (success หรือ failed)
+// ถ้ายังไม่ได้เรียก Start จะเริ่ม attempt ให้ก่อน
+func (l *AttemptLog) Finish(filename, status string, stats AttemptStats, errorMessage string) error {
+	if err := l.Start(filename); err != nil {
+		return err
+	}
+	record := l.open[filename]
+	delete(l.open, filename)
+
+	finishedAt := time.Now()
+	record.Status = status
+	record.FinishedAt = &finishedAt
+	record.DurationMs = finishedAt.Sub(record.StartedAt).Milliseconds()
+	record.RowsRead = stats.RowsRead
+	record.RowsWritten = stats.RowsWritten
+	record.RowsRejected = stats.RowsRejected
+	record.LoadMode = stats.Load.Mode
+	record.InsertedCount = stats.Load.Inserted
+	record.UpdatedCount = stats.Load.Updated
+	record.UnchangedCount = stats.Load.Unchanged
+	record.ErrorMessage = errorMessage
+
+	var err error
+	if record.ID == 0 {
+		// Start บันทึกไม่สำเร็จ จึงบันทึกเป็นแถวใหม่
+		err = l.db.Create(record).Error
+	} else {
+		err = l.db.Save(record).Error
+	}
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกประวัติการประมวลผลไฟล์ %s ได้: %v", filename, err)
+	}
+	return nil
+}
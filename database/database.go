@@ -1,14 +1,15 @@
 package database
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"mcmc/config"
 	"mcmc/models"
+	"mcmc/retry"
+	"mcmc/secret"
 	"time"
 
-	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -18,38 +19,80 @@ type DB struct {
 	*gorm.DB
 }
 
-// NewDB สร้างการเชื่อมต่อใหม่กับฐานข้อมูล
+// NewDB สร้างการเชื่อมต่อใหม่กับฐานข้อมูลตามชนิดใน cfg.Driver
 func NewDB(cfg config.DatabaseConfig) (*DB, error) {
-	dsn := fmt.Sprintf("server=%s;user id=%s;password=%s;port=%s;database=%s;encrypt=disable",
-		cfg.Host, cfg.User, cfg.Password, cfg.Port, cfg.DBName)
+	dialector, err := dialector(cfg)
+	if err != nil {
+		return nil, retry.Permanent(err)
+	}
 
 	// ตั้งค่า GORM
 	config := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	}
 
-	db, err := gorm.Open(sqlserver.Open(dsn), config)
+	db, err := gorm.Open(dialector, config)
 	if err != nil {
-		return nil, fmt.Errorf("ไม่สามารถเชื่อมต่อกับฐานข้อมูลได้: %v", err)
+		redacted := fmt.Errorf("ไม่สามารถเชื่อมต่อกับฐานข้อมูลได้: %s", secret.Redact(err.Error(), cfg.Password))
+		if isLoginError(err) {
+			return nil, retry.Permanent(redacted)
+		}
+		return nil, redacted
 	}
 
 	// ตั้งค่า connection pool
 	sqlDB, err := db.DB()
 	if err != nil {
-		return nil, fmt.Errorf("ไม่สามารถรับ underlying database connection ได้: %v", err)
+		return nil, fmt.Errorf("ไม่สามารถรับ underlying database connection ได้: %s", secret.Redact(err.Error(), cfg.Password))
 	}
 
-	sqlDB.SetMaxOpenConns(25)
-	sqlDB.SetMaxIdleConns(5)
-	sqlDB.SetConnMaxLifetime(5 * time.Minute)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
 	return &DB{db}, nil
 }
 
-// CheckFileProcessed ตรวจสอบว่าไฟล์เคยประมวลผลแล้วหรือไม่
+// Connect สร้างการเชื่อมต่อกับฐานข้อมูลและลองใหม่ตาม policy เมื่อเกิดข้อผิดพลาดชั่วคราว
+// ข้อผิดพลาดจากการ login (รหัสผ่านผิด ไม่มีสิทธิ์เข้าฐานข้อมูล) จะไม่ลองใหม่
+func Connect(ctx context.Context, cfg config.DatabaseConfig, policy retry.Policy) (*DB, error) {
+	var db *DB
+	err := retry.Do(ctx, policy, "การเชื่อมต่อฐานข้อมูล", func() error {
+		var err error
+		db, err = NewDB(cfg)
+		return err
+	})
+	return db, err
+}
+
+// WithContext คืน DB ที่ใช้ ctx กับทุกคำสั่ง เมื่อ ctx ถูกยกเลิกคำสั่งที่ค้างอยู่จะหยุดและ transaction จะ rollback
+func (db *DB) WithContext(ctx context.Context) *DB {
+	return &DB{db.DB.WithContext(ctx)}
+}
+
+// Ping ตรวจสอบว่ายังเชื่อมต่อกับฐานข้อมูลได้
+func (db *DB) Ping(ctx context.Context) error {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Close ปิด connection pool ของฐานข้อมูล
+func (db *DB) Close() error {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// CheckFileProcessed ตรวจสอบว่าไฟล์เคยประมวลผลสำเร็จแล้วหรือไม่ (attempt ใดก็ได้)
 func (db *DB) CheckFileProcessed(filename string) (bool, error) {
 	var count int64
-	query := "SELECT COUNT(*) FROM file_processing_logs WHERE filename = ? AND status = 'success'"
+	query := "SELECT COUNT(*) FROM file_processing_attempts WHERE filename = ? AND status = 'success'"
 	
 	err := db.DB.Raw(query, filename).Count(&count).Error
 	if err != nil {
@@ -59,11 +102,85 @@ func (db *DB) CheckFileProcessed(filename string) (bool, error) {
 	return count > 0, nil
 }
 
-// LogFileProcessing บันทึกประวัติการประมวลผลไฟล์
-func (db *DB) LogFileProcessing(filename, status string, recordCount int, errorMessage string) error {
-	log := models.FileProcessingLog{
-		Filename:     filename,
+// ProcessedFilesByPrefix คืนชื่อไฟล์ที่ขึ้นต้นด้วย prefix และเคยประมวลผลสำเร็จแล้ว
+// ไฟล์ที่สำเร็จแล้วแต่การประมวลผลซ้ำครั้งล่าสุดล้มเหลวยังนับว่าประมวลผลแล้ว เพราะข้อมูลเดิมยังอยู่
+func (db *DB) ProcessedFilesByPrefix(prefix string) (map[string]bool, error) {
+	var filenames []string
+	query := "SELECT DISTINCT filename FROM file_processing_attempts WHERE filename LIKE ? AND status = 'success'"
+
+	err := db.DB.Raw(query, prefix+"%").Scan(&filenames).Error
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงประวัติการประมวลผลไฟล์ได้: %v", err)
+	}
+
+	processed := make(map[string]bool, len(filenames))
+	for _, filename := range filenames {
+		processed[filename] = true
+	}
+
+	return processed, nil
+}
+
+// FileStatusesByPrefix คืนสถานะปัจจุบันของไฟล์ที่ขึ้นต้นด้วย prefix จาก view file_processing_status
+func (db *DB) FileStatusesByPrefix(prefix string) (map[string]models.FileProcessingStatus, error) {
+	var statuses []models.FileProcessingStatus
+	if err := db.Where("filename LIKE ?", prefix+"%").Find(&statuses).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงสถานะการประมวลผลไฟล์ได้: %v", err)
+	}
+
+	byName := make(map[string]models.FileProcessingStatus, len(statuses))
+	for _, status := range statuses {
+		byName[status.Filename] = status
+	}
+	return byName, nil
+}
+
+// HistoryFilter กำหนดเงื่อนไขของ FileProcessingHistory (ค่าว่างหมายถึงไม่กรอง)
+type HistoryFilter struct {
+	Filename string // ชื่อไฟล์ที่มีข้อความนี้
+	Status   string
+	Limit    int // จำนวนรายการสูงสุด (0 = ไม่จำกัด)
+}
+
+// FileProcessingHistory คืนประวัติการประมวลผลไฟล์ทุก attempt เรียงจากล่าสุด
+func (db *DB) FileProcessingHistory(filter HistoryFilter) ([]models.FileProcessingAttempt, error) {
+	query := db.Model(&models.FileProcessingAttempt{}).Order("started_at DESC, id DESC")
+	if filter.Filename != "" {
+		query = query.Where("filename LIKE ?", "%"+filter.Filename+"%")
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var attempts []models.FileProcessingAttempt
+	if err := query.Find(&attempts).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงประวัติการประมวลผลไฟล์ได้: %v", err)
+	}
+	return attempts, nil
+}
+
+// CheckBatchProcessed ตรวจสอบว่าชุดไฟล์เคยประมวลผลสำเร็จแล้วหรือไม่
+func (db *DB) CheckBatchProcessed(batchID string) (bool, error) {
+	var count int64
+	query := "SELECT COUNT(*) FROM batch_processing_logs WHERE batch_id = ? AND status = 'success'"
+
+	err := db.DB.Raw(query, batchID).Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("ไม่สามารถตรวจสอบประวัติการประมวลผลชุดไฟล์ได้: %v", err)
+	}
+
+	return count > 0, nil
+}
+
+// LogBatchProcessing บันทึกผลการประมวลผลของชุดไฟล์
+func (db *DB) LogBatchProcessing(batchID, status string, fileCount, recordCount int, errorMessage string) error {
+	log := models.BatchProcessingLog{
+		BatchID:      batchID,
 		Status:       status,
+		FileCount:    fileCount,
 		RecordCount:  recordCount,
 		ErrorMessage: errorMessage,
 		CreatedAt:    time.Now(),
@@ -71,30 +188,30 @@ func (db *DB) LogFileProcessing(filename, status string, recordCount int, errorM
 
 	result := db.Create(&log)
 	if result.Error != nil {
-		return fmt.Errorf("ไม่สามารถบันทึกประวัติการประมวลผลไฟล์ได้: %v", result.Error)
+		return fmt.Errorf("ไม่สามารถบันทึกประวัติการประมวลผลชุดไฟล์ได้: %v", result.Error)
 	}
 
 	return nil
 }
 
-// SaveSaleOrderHeader บันทึกข้อมูล header ลงฐานข้อมูล
-func (db *DB) SaveSaleOrderHeader(headers []models.SaleOrderHeader) (int, error) {
-	result := db.Create(&headers)
-	if result.Error != nil {
-		return 0, fmt.Errorf("ไม่สามารถบันทึกข้อมูล header ได้: %v", result.Error)
-	}
-
-	return len(headers), nil
+// RunInTransaction รันฟังก์ชันภายใน database transaction เดียว
+// ถ้าฟังก์ชันคืน error จะ rollback ทั้งหมด
+func (db *DB) RunInTransaction(fn func(tx *DB) error) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		return fn(&DB{tx})
+	})
 }
 
-// SaveSaleOrderItem บันทึกข้อมูล item ลงฐานข้อมูล
-func (db *DB) SaveSaleOrderItem(items []models.SaleOrderItem) (int, error) {
-	result := db.Create(&items)
-	if result.Error != nil {
-		return 0, fmt.Errorf("ไม่สามารถบันทึกข้อมูล item ได้: %v", result.Error)
-	}
+// NewSaleOrderHeaderLoader สร้าง Loader สำหรับบันทึกข้อมูล header ตามวิธีที่กำหนด (natural key คือ DocNo)
+// bulk = ใช้ bulk copy แทน INSERT (ดู Loader.UseBulkCopy)
+func (db *DB) NewSaleOrderHeaderLoader(mode string, bulk bool) *Loader {
+	return db.NewLoader("header", mode, []string{"doc_no"}).UseBulkCopy(bulk)
+}
 
-	return len(items), nil
+// NewSaleOrderItemLoader สร้าง Loader สำหรับบันทึกข้อมูล item ตามวิธีที่กำหนด (natural key คือ DocNo+ItemID)
+// ในโหมด replace จะลบ item เดิมทั้งหมดของ DocNo ที่อยู่ในไฟล์ก่อน insert
+func (db *DB) NewSaleOrderItemLoader(mode string, bulk bool) *Loader {
+	return db.NewLoader("item", mode, []string{"doc_no", "item_id"}).UseBulkCopy(bulk)
 }
 
 // SaveSaleOrderSummary บันทึกข้อมูล summary ลงฐานข้อมูล
@@ -107,33 +224,43 @@ func (db *DB) SaveSaleOrderSummary(summaries []models.SaleOrderSummary) (int, er
 	return len(summaries), nil
 }
 
-func (db *DB) MigrateDB() error {
-	log.Println("กำลังทำ database migration...")
+// DeleteBySourceFile ลบแถวของ model ที่บันทึกจากไฟล์ sourceFile
+func (db *DB) DeleteBySourceFile(model interface{}, sourceFile string) (int, error) {
+	result := db.Where("source_file = ?", sourceFile).Delete(model)
+	if result.Error != nil {
+		return 0, fmt.Errorf("ไม่สามารถลบข้อมูลของไฟล์ %s ได้: %v", sourceFile, result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
 
-	originalLogger := db.Logger
+// GetSaleOrderSummaries ดึงข้อมูล summary ที่บันทึกจากไฟล์ที่กำหนด
+func (db *DB) GetSaleOrderSummaries(sourceFile string) ([]models.SaleOrderSummary, error) {
+	var summaries []models.SaleOrderSummary
+	err := db.Where("source_file = ?", sourceFile).Find(&summaries).Error
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึงข้อมูล summary ได้: %v", err)
+	}
 
-	silentLogger := logger.New(
-		log.New(ioutil.Discard, "", 0), 
-		logger.Config{
-			LogLevel: logger.Silent,
-		},
-	)
-	
-	db.Logger = silentLogger
-	
-	err := db.DB.AutoMigrate(
-		&models.FileProcessingLog{},
-		&models.SaleOrderHeader{},
-		&models.SaleOrderItem{},
-		&models.SaleOrderSummary{},
-	)
-
-	db.Logger = originalLogger
-	
+	return summaries, nil
+}
+
+// SaveBatchReconciliation บันทึกผลการตรวจสอบจำนวนรายการของชุดไฟล์
+func (db *DB) SaveBatchReconciliation(reconciliation *models.BatchReconciliation) error {
+	result := db.Create(reconciliation)
+	if result.Error != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกผลการตรวจสอบจำนวนรายการได้: %v", result.Error)
+	}
+
+	return nil
+}
+
+// MigrateDB ทำ migration ที่ยังไม่ได้ทำทั้งหมด (ดู MigrateUp)
+func (db *DB) MigrateDB() error {
+	log.Println("กำลังทำ database migration...")
+	count, err := db.MigrateUp(0)
 	if err != nil {
 		return fmt.Errorf("ไม่สามารถทำ migration ได้: %v", err)
 	}
-	
-	log.Println("ทำ database migration สำเร็จ")
+	log.Printf("ทำ database migration สำเร็จ (%d เวอร์ชัน)", count)
 	return nil
 }
\ No newline at end of file
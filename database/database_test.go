@@ -0,0 +1,80 @@
+package database
+
+import (
+	"mcmc/config"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm/logger"
+)
+
+// openTestSQLite สร้างฐานข้อมูล SQLite ใหม่ในโฟลเดอร์ชั่วคราวของ test
+func openTestSQLite(t *testing.T) *DB {
+	t.Helper()
+	db, err := NewDB(config.DatabaseConfig{Driver: DriverSQLite, DBName: filepath.Join(t.TempDir(), "mcmc.db")})
+	if err != nil {
+		t.Fatalf("ไม่สามารถสร้างฐานข้อมูล SQLite ได้: %v", err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	db := openTestSQLite(t)
+	migrations, err := loadMigrations(DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := db.MigrateUp(0)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("MigrateUp ทำ %d เวอร์ชัน ต้องการ %d", applied, len(migrations))
+	}
+	for _, table := range []string{"file_processing_attempts", "batch_processing_logs", "saleorder_header", "saleorder_item"} {
+		if !db.Migrator().HasTable(table) {
+			t.Errorf("ไม่พบตาราง %s หลัง MigrateUp", table)
+		}
+	}
+	if !db.Migrator().HasColumn("file_processing_attempts", "sha256") {
+		t.Errorf("ไม่พบคอลัมน์ file_processing_attempts.sha256 หลัง MigrateUp")
+	}
+
+	// ทำซ้ำต้องไม่มีเวอร์ชันค้าง
+	if applied, err := db.MigrateUp(0); err != nil || applied != 0 {
+		t.Errorf("MigrateUp ครั้งที่สอง = %d, %v ต้องการ 0, nil", applied, err)
+	}
+
+	reverted, err := db.MigrateDown(1)
+	if err != nil || reverted != 1 {
+		t.Fatalf("MigrateDown(1) = %d, %v ต้องการ 1, nil", reverted, err)
+	}
+	if db.Migrator().HasColumn("file_processing_attempts", "sha256") {
+		t.Errorf("คอลัมน์ sha256 ยังอยู่หลังย้อน migration ล่าสุด")
+	}
+
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, status := range statuses {
+		want := i < len(statuses)-1
+		if status.Applied != want {
+			t.Errorf("migration %04d_%s Applied = %v ต้องการ %v", status.Version, status.Name, status.Applied, want)
+		}
+	}
+	if pending, err := db.PendingMigrations(); err != nil || pending != 1 {
+		t.Errorf("PendingMigrations = %d, %v ต้องการ 1, nil", pending, err)
+	}
+
+	// ย้อนทั้งหมดแล้วทำใหม่ได้
+	if _, err := db.MigrateDown(len(migrations)); err != nil {
+		t.Fatalf("MigrateDown ทั้งหมด: %v", err)
+	}
+	if applied, err := db.MigrateUp(0); err != nil || applied != len(migrations) {
+		t.Errorf("MigrateUp หลังย้อนทั้งหมด = %d, %v ต้องการ %d, nil", applied, err, len(migrations))
+	}
+}
@@ -0,0 +1,255 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"mcmc/config"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/microsoft/go-mssqldb/azuread"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// ชนิดฐานข้อมูลที่รองรับ (ค่าของ database.driver)
+const (
+	DriverSQLServer = "sqlserver"
+	DriverPostgres  = "postgres"
+	DriverMySQL     = "mysql"
+	DriverSQLite    = "sqlite" // db_name คือ path ของไฟล์ฐานข้อมูล
+)
+
+// defaultPorts คือ port ที่ใช้เมื่อไม่ได้กำหนด database.port
+var defaultPorts = map[string]string{
+	DriverSQLServer: "1433",
+	DriverPostgres:  "5432",
+	DriverMySQL:     "3306",
+}
+
+// dialector สร้าง GORM dialector ตาม cfg.Driver
+func dialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	port := cfg.Port
+	if port == "" {
+		port = defaultPorts[cfg.Driver]
+	}
+
+	switch cfg.Driver {
+	case DriverSQLServer:
+		// named instance ที่ไม่ได้ระบุ port ให้ driver หา port จาก SQL Browser
+		if cfg.Instance != "" && cfg.Port == "" {
+			port = ""
+		}
+		// CA ที่อ่านไม่ได้ทำให้เชื่อมต่อไม่ได้ทุกครั้ง จึงตรวจสอบก่อนเพื่อไม่ให้ลองใหม่
+		if cfg.CAFile != "" {
+			if _, err := os.ReadFile(cfg.CAFile); err != nil {
+				return nil, fmt.Errorf("ไม่สามารถอ่านไฟล์ CA %s ได้: %v", cfg.CAFile, err)
+			}
+		}
+		driverName, dsn := sqlServerDSN(cfg, port)
+		return sqlServerDialector{sqlserver.New(sqlserver.Config{DriverName: driverName, DSN: dsn}).(*sqlserver.Dialector)}, nil
+
+	case DriverPostgres:
+		query := url.Values{}
+		if cfg.AppName != "" {
+			query.Set("application_name", cfg.AppName)
+		}
+		if cfg.ConnectTimeout > 0 {
+			query.Set("connect_timeout", timeoutSeconds(cfg.ConnectTimeout))
+		}
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(cfg.User, cfg.Password),
+			Host:     net.JoinHostPort(cfg.Host, port),
+			Path:     "/" + cfg.DBName,
+			RawQuery: query.Encode(),
+		}
+		return postgres.Open(dsn.String()), nil
+
+	case DriverMySQL:
+		dsn := mysql.NewConfig()
+		dsn.User = cfg.User
+		dsn.Passwd = cfg.Password
+		dsn.Net = "tcp"
+		dsn.Addr = net.JoinHostPort(cfg.Host, port)
+		dsn.DBName = cfg.DBName
+		dsn.ParseTime = true
+		dsn.Loc = time.Local
+		dsn.Timeout = cfg.ConnectTimeout
+		dsn.Params = map[string]string{"charset": "utf8mb4"}
+		return gormmysql.Open(dsn.FormatDSN()), nil
+
+	case DriverSQLite:
+		// รอ lock ของไฟล์แทนการคืน SQLITE_BUSY ทันทีเมื่อมีการเขียนจากหลาย connection
+		return sqlite.Open(cfg.DBName + "?_busy_timeout=5000&_journal_mode=WAL"), nil
+	}
+	return nil, fmt.Errorf("ไม่รู้จักฐานข้อมูลชนิด %q (รองรับ %s, %s, %s, %s)",
+		cfg.Driver, DriverSQLServer, DriverPostgres, DriverMySQL, DriverSQLite)
+}
+
+// sqlServerDSN สร้าง connection string ของ SQL Server และคืนชื่อ driver ที่ต้องใช้
+// (azuresql เมื่อยืนยันตัวตนด้วย Azure AD) ค่าทุกตัวถูก encode ใน URL จึงมีอักขระพิเศษได้
+func sqlServerDSN(cfg config.DatabaseConfig, port string) (string, string) {
+	query := url.Values{}
+	query.Set("database", cfg.DBName)
+	query.Set("encrypt", cfg.Encrypt)
+	if cfg.Encrypt == "" {
+		query.Set("encrypt", "disable")
+	}
+	if cfg.CAFile != "" {
+		query.Set("certificate", cfg.CAFile)
+	}
+	if cfg.ServerCertHostname != "" {
+		query.Set("hostNameInCertificate", cfg.ServerCertHostname)
+	}
+	if cfg.TrustServerCertificate {
+		query.Set("TrustServerCertificate", "true")
+	}
+	if cfg.AppName != "" {
+		query.Set("app name", cfg.AppName)
+	}
+	if cfg.ConnectTimeout > 0 {
+		query.Set("connection timeout", timeoutSeconds(cfg.ConnectTimeout))
+		query.Set("dial timeout", timeoutSeconds(cfg.ConnectTimeout))
+	}
+
+	driverName := "sqlserver"
+	switch cfg.Authentication {
+	case "ntlm":
+		query.Set("authenticator", "ntlm")
+	case "azure_ad":
+		driverName = azuread.DriverName
+		query.Set("fedauth", cfg.FedAuth)
+	}
+
+	dsn := url.URL{
+		Scheme:   "sqlserver",
+		Host:     cfg.Host,
+		Path:     cfg.Instance,
+		RawQuery: query.Encode(),
+	}
+	if port != "" {
+		dsn.Host = net.JoinHostPort(cfg.Host, port)
+	}
+	switch {
+	case cfg.Password != "":
+		dsn.User = url.UserPassword(cfg.User, cfg.Password)
+	case cfg.User != "":
+		dsn.User = url.User(cfg.User)
+	}
+	return driverName, dsn.String()
+}
+
+// timeoutSeconds แปลง d เป็นจำนวนวินาที (ปัดขึ้น) สำหรับ connection string
+func timeoutSeconds(d time.Duration) string {
+	return strconv.Itoa(int((d + time.Second - 1) / time.Second))
+}
+
+// sqlServerDialector ใช้ datetime2 กับคอลัมน์เวลาแทน datetimeoffset ซึ่งเป็นค่าเริ่มต้นของ GORM
+// เพื่อให้ตรงกับตารางที่สร้างไว้แล้วใน SQL Server
+type sqlServerDialector struct {
+	*sqlserver.Dialector
+}
+
+func (d sqlServerDialector) DataTypeOf(field *schema.Field) string {
+	if field.DataType == schema.Time {
+		return "datetime2"
+	}
+	return d.Dialector.DataTypeOf(field)
+}
+
+// Migrator ต้องสร้างใหม่เพื่อให้ migration ใช้ DataTypeOf ของ sqlServerDialector
+func (d sqlServerDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return sqlserver.Migrator{Migrator: migrator.Migrator{Config: migrator.Config{
+		DB:                          db,
+		Dialector:                   d,
+		CreateIndexAfterCreateTable: true,
+	}}}
+}
+
+// driver คืนชนิดของฐานข้อมูลที่เชื่อมต่ออยู่
+func (db *DB) driver() string {
+	return db.Dialector.Name()
+}
+
+// loginErrors คือหมายเลข error ของ SQL Server ที่ลองใหม่แล้วก็ไม่สำเร็จ
+var loginErrors = map[int32]bool{
+	18456: true, // login failed
+	18486: true, // account ถูกล็อก
+	18487: true, // รหัสผ่านหมดอายุ
+	18488: true, // ต้องเปลี่ยนรหัสผ่าน
+	4060:  true, // เปิดฐานข้อมูลที่ระบุไม่ได้
+}
+
+// pgLoginErrors คือ SQLSTATE ของ PostgreSQL ที่ลองใหม่แล้วก็ไม่สำเร็จ
+var pgLoginErrors = map[string]bool{
+	"28000": true, // ไม่มีสิทธิ์ login
+	"28P01": true, // รหัสผ่านผิด
+	"3D000": true, // ไม่มีฐานข้อมูลที่ระบุ
+}
+
+// mysqlLoginErrors คือหมายเลข error ของ MySQL ที่ลองใหม่แล้วก็ไม่สำเร็จ
+var mysqlLoginErrors = map[uint16]bool{
+	1044: true, // ไม่มีสิทธิ์เข้าฐานข้อมูล
+	1045: true, // รหัสผ่านผิด
+	1049: true, // ไม่มีฐานข้อมูลที่ระบุ
+}
+
+func isLoginError(err error) bool {
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) {
+		return loginErrors[sqlErr.Number]
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgLoginErrors[pgErr.Code]
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlLoginErrors[mysqlErr.Number]
+	}
+	return false
+}
+
+// quote ครอบชื่อคอลัมน์ตามรูปแบบของฐานข้อมูล
+func (db *DB) quote(name string) string {
+	switch db.driver() {
+	case DriverSQLServer:
+		return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+	case DriverMySQL:
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteTable ครอบชื่อตารางที่อาจระบุ schema ไว้ด้วย เช่น dbo.saleorder_payment
+func (db *DB) quoteTable(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = db.quote(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// isDistinct คืนเงื่อนไขที่เป็นจริงเมื่อ a และ b ต่างกัน โดยถือว่า NULL เท่ากับ NULL
+func (db *DB) isDistinct(a, b string) string {
+	switch db.driver() {
+	case DriverMySQL:
+		return fmt.Sprintf("NOT (%s <=> %s)", a, b)
+	case DriverSQLite:
+		return fmt.Sprintf("%s IS NOT %s", a, b)
+	}
+	return fmt.Sprintf("%s IS DISTINCT FROM %s", a, b)
+}
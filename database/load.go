@@ -0,0 +1,426 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// วิธีบันทึกข้อมูลที่รองรับ
+const (
+	LoadModeAppend  = "append"  // insert ทุกแถว (ค่าเริ่มต้น)
+	LoadModeUpsert  = "upsert"  // insert หรืออัปเดตตาม natural key (MERGE ใน SQL Server)
+	LoadModeReplace = "replace" // ลบแถวเดิมที่มี DocNo เดียวกันแล้ว insert ใหม่
+)
+
+// maxParams คือจำนวน parameter สูงสุดที่ใช้ต่อหนึ่งคำสั่ง (SQL Server จำกัดที่ 2100)
+const maxParams = 2000
+
+// LoadResult สรุปผลการบันทึกข้อมูลของไฟล์หนึ่งไฟล์
+type LoadResult struct {
+	Mode      string
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Deleted   int // จำนวนแถวเดิมที่ถูกลบในโหมด replace
+}
+
+// Total คืนจำนวนแถวทั้งหมดที่ประมวลผล
+func (r LoadResult) Total() int {
+	return r.Inserted + r.Updated + r.Unchanged
+}
+
+func (r *LoadResult) add(other LoadResult) {
+	r.Inserted += other.Inserted
+	r.Updated += other.Updated
+	r.Unchanged += other.Unchanged
+	r.Deleted += other.Deleted
+}
+
+// Loader บันทึกข้อมูลของไฟล์หนึ่งไฟล์ทีละช่วงตามวิธีที่กำหนด และสะสมผลของทุกช่วง
+// ควรใช้ภายใน transaction เดียวเพื่อให้ทั้งไฟล์ commit หรือ rollback พร้อมกัน
+type Loader struct {
+	db       *DB
+	name     string // ชื่อข้อมูลสำหรับข้อความ error
+	mode     string
+	keys     []string // natural key โดยโหมด replace จะลบแถวเดิมตามคอลัมน์แรก
+	table    string   // ตารางปลายทางของ LoadRows
+	columns  []Column // คอลัมน์ของ LoadRows
+	bulk     bool     // ใช้ bulk copy แทน INSERT ในโหมด append และ replace
+	replaced map[interface{}]bool
+	result   LoadResult
+}
+
+// NewLoader สร้าง Loader สำหรับบันทึก slice ของ model ด้วย Load
+func (db *DB) NewLoader(name, mode string, keys []string) *Loader {
+	if mode == "" {
+		mode = LoadModeAppend
+	}
+	return &Loader{
+		db:       db,
+		name:     name,
+		mode:     mode,
+		keys:     keys,
+		replaced: map[interface{}]bool{},
+		result:   LoadResult{Mode: mode},
+	}
+}
+
+// NewTableLoader สร้าง Loader สำหรับบันทึกแถวลงตารางที่ไม่มี model ด้วย LoadRows
+func (db *DB) NewTableLoader(table string, columns []Column, mode string, keys []string) *Loader {
+	loader := db.NewLoader(table, mode, keys)
+	loader.table = table
+	loader.columns = columns
+	return loader
+}
+
+// UseBulkCopy กำหนดให้ Load ใช้ bulk copy ของ SQL Server แทน INSERT ในโหมด append และ replace
+// (โหมด upsert ใช้ MERGE เสมอ และฐานข้อมูลอื่นจะใช้ INSERT ตามปกติ)
+func (l *Loader) UseBulkCopy(enabled bool) *Loader {
+	l.bulk = enabled
+	return l
+}
+
+// Result คืนผลรวมของทุกช่วงที่บันทึกแล้ว
+func (l *Loader) Result() LoadResult {
+	return l.result
+}
+
+// Load บันทึก records (pointer ของ slice ของ model) หนึ่งช่วง
+// ในโหมด replace แถวเดิมของแต่ละ key จะถูกลบเพียงครั้งแรกที่พบ แถวที่บันทึกจากช่วงก่อนหน้าจึงไม่ถูกลบ
+func (l *Loader) Load(records interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(records))
+	if rv.Len() == 0 {
+		return nil
+	}
+
+	var result LoadResult
+	var err error
+	switch l.mode {
+	case LoadModeAppend:
+		err = l.db.insertRecords(records, l.bulk)
+		result.Inserted = rv.Len()
+
+	case LoadModeUpsert:
+		err = l.db.RunInTransaction(func(tx *DB) error {
+			var err error
+			result, err = tx.mergeRecords(records, l.keys)
+			return err
+		})
+
+	case LoadModeReplace:
+		err = l.db.RunInTransaction(func(tx *DB) error {
+			deleted, err := tx.deleteByColumn(records, l.keys[0], l.replaced)
+			if err != nil {
+				return err
+			}
+			if err := tx.insertRecords(records, l.bulk); err != nil {
+				return err
+			}
+			result.Deleted = deleted
+			result.Inserted = rv.Len()
+			return nil
+		})
+
+	default:
+		err = fmt.Errorf("ไม่รู้จักวิธีบันทึกข้อมูล: %s", l.mode)
+	}
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกข้อมูล %s ได้: %v", l.name, err)
+	}
+
+	l.result.add(result)
+	return nil
+}
+
+// insertRecords insert records ด้วย bulk copy หรือ INSERT ทีละช่วง
+func (db *DB) insertRecords(records interface{}, bulk bool) error {
+	if bulk && db.driver() == DriverSQLServer {
+		return db.bulkCopy(records)
+	}
+	return db.createInBatches(records)
+}
+
+// createInBatches insert records ทีละช่วงตามจำนวน parameter และจำนวนแถวสูงสุดต่อคำสั่ง
+func (db *DB) createInBatches(records interface{}) error {
+	sch, err := db.parseSchema(records)
+	if err != nil {
+		return err
+	}
+	return db.CreateInBatches(records, batchSize(len(sch.DBNames))).Error
+}
+
+// batchSize คืนจำนวนแถวสูงสุดต่อคำสั่งสำหรับตารางที่มี columns คอลัมน์
+func batchSize(columns int) int {
+	size := maxParams / columns
+	if size > maxInsertRows {
+		size = maxInsertRows
+	}
+	return size
+}
+
+// deleteByColumn ลบแถวเดิมในตารางที่มีค่าคอลัมน์ตรงกับค่าใน records
+// ค่าที่อยู่ใน done แล้วจะถูกข้าม และค่าที่ลบแล้วจะถูกเพิ่มลงใน done
+func (db *DB) deleteByColumn(records interface{}, column string, done map[interface{}]bool) (int, error) {
+	sch, err := db.parseSchema(records)
+	if err != nil {
+		return 0, err
+	}
+	field := sch.LookUpField(column)
+	if field == nil {
+		return 0, fmt.Errorf("ไม่พบคอลัมน์ %s ในตาราง %s", column, sch.Table)
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(records))
+	var values []interface{}
+	for i := 0; i < rv.Len(); i++ {
+		v, _ := field.ValueOf(context.Background(), rv.Index(i))
+		if !done[v] {
+			done[v] = true
+			values = append(values, v)
+		}
+	}
+
+	return db.deleteIn(sch.Table, column, values)
+}
+
+// deleteIn ลบแถวในตารางที่ค่าคอลัมน์อยู่ใน values ทีละช่วงตามจำนวน parameter สูงสุด
+func (db *DB) deleteIn(table, column string, values []interface{}) (int, error) {
+	deleted := 0
+	for start := 0; start < len(values); start += maxParams {
+		end := start + maxParams
+		if end > len(values) {
+			end = len(values)
+		}
+		res := db.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s IN ?", db.quoteTable(table), db.quote(column)), values[start:end])
+		if res.Error != nil {
+			return deleted, res.Error
+		}
+		deleted += int(res.RowsAffected)
+	}
+	return deleted, nil
+}
+
+// mergeRecords บันทึก records ตาม natural key (ดู mergeRows)
+func (db *DB) mergeRecords(records interface{}, keys []string) (LoadResult, error) {
+	result := LoadResult{Mode: LoadModeUpsert}
+
+	sch, err := db.parseSchema(records)
+	if err != nil {
+		return result, err
+	}
+
+	var columns []*schema.Field
+	for _, f := range sch.Fields {
+		if f.DBName == "" || f.PrimaryKey {
+			continue
+		}
+		columns = append(columns, f)
+	}
+
+	for _, k := range keys {
+		if sch.LookUpField(k) == nil {
+			return result, fmt.Errorf("ไม่พบคอลัมน์ %s ในตาราง %s", k, sch.Table)
+		}
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(records))
+	ctx := context.Background()
+	rows := make([][]interface{}, rv.Len())
+	for i := range rows {
+		rows[i] = make([]interface{}, len(columns))
+		for j, f := range columns {
+			rows[i][j], _ = f.ValueOf(ctx, rv.Index(i))
+		}
+	}
+
+	names := make([]string, len(columns))
+	types := make([]string, len(columns))
+	for i, f := range columns {
+		names[i] = f.DBName
+		types[i] = db.Dialector.DataTypeOf(f)
+	}
+
+	return db.mergeRows(sch.Table, names, types, keys, rows)
+}
+
+// mergeRows บันทึก rows ด้วยคำสั่ง MERGE (หรือ upsertChunk ในฐานข้อมูลอื่น) ทีละช่วงตามจำนวน parameter สูงสุด
+// types[i] คือชนิดข้อมูลของ columns[i] ที่ใช้ CAST ค่าจากไฟล์ (ว่าง = ไม่ CAST)
+// แถวที่ key ตรงกันจะถูกอัปเดต (รวม source_file) และนับเป็น unchanged ถ้าข้อมูลธุรกิจไม่เปลี่ยน
+// ถ้าใน rows มี key ซ้ำกันจะใช้แถวสุดท้าย
+func (db *DB) mergeRows(table string, columns, types, keys []string, rows [][]interface{}) (LoadResult, error) {
+	result := LoadResult{Mode: LoadModeUpsert}
+
+	keySet := map[string]bool{}
+	for _, k := range keys {
+		keySet[k] = true
+	}
+
+	// คอลัมน์ที่ใช้ตัดสินว่าข้อมูลเปลี่ยนหรือไม่ (ไม่รวม key และข้อมูล lineage)
+	var compare []string
+	var update []string
+	for _, c := range columns {
+		if keySet[c] || c == "created_at" {
+			continue
+		}
+		update = append(update, c)
+		if c != "source_file" {
+			compare = append(compare, c)
+		}
+	}
+
+	// ตัดแถวที่ key ซ้ำกันออก โดยเก็บแถวสุดท้ายไว้
+	var unique [][]interface{}
+	index := map[string]int{}
+	for _, row := range rows {
+		var keyParts []string
+		for j, c := range columns {
+			if keySet[c] {
+				keyParts = append(keyParts, fmt.Sprint(row[j]))
+			}
+		}
+		key := strings.Join(keyParts, "\x00")
+		if idx, ok := index[key]; ok {
+			unique[idx] = row
+			continue
+		}
+		index[key] = len(unique)
+		unique = append(unique, row)
+	}
+
+	// ฐานข้อมูลที่ไม่มีคำสั่ง MERGE ใช้ upsertChunk แทน (ดู upsert.go)
+	chunk := db.mergeChunk
+	chunkSize := maxParams / len(columns)
+	if db.driver() != DriverSQLServer {
+		chunk = db.upsertChunk
+		if chunkSize > maxUnionRows {
+			chunkSize = maxUnionRows
+		}
+	}
+	for start := 0; start < len(unique); start += chunkSize {
+		end := start + chunkSize
+		if end > len(unique) {
+			end = len(unique)
+		}
+
+		kinds, err := chunk(table, columns, types, keys, compare, update, unique[start:end])
+		if err != nil {
+			return result, err
+		}
+		for _, kind := range kinds {
+			switch kind {
+			case "inserted":
+				result.Inserted++
+			case "updated":
+				result.Updated++
+			default:
+				result.Unchanged++
+			}
+		}
+	}
+
+	result.Unchanged += len(rows) - len(unique)
+	return result, nil
+}
+
+// mergeChunk บันทึก rows หนึ่งช่วงด้วยคำสั่ง MERGE และคืนผลของแต่ละแถว (inserted, updated หรือ unchanged)
+func (db *DB) mergeChunk(table string, columns, types, keys, compare, update []string, rows [][]interface{}) ([]string, error) {
+	query, args := db.buildMerge(table, columns, types, keys, compare, update, rows)
+	sqlRows, err := db.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer sqlRows.Close()
+
+	var kinds []string
+	for sqlRows.Next() {
+		var kind string
+		if err := sqlRows.Scan(&kind); err != nil {
+			return nil, err
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, sqlRows.Err()
+}
+
+// buildMerge สร้างคำสั่ง MERGE สำหรับข้อมูลหนึ่งช่วง
+// ค่าจากไฟล์ถูก CAST เป็นชนิดเดียวกับคอลัมน์ปลายทางก่อนเปรียบเทียบ เพื่อไม่ให้ทศนิยมที่ถูกปัดนับเป็นการเปลี่ยนแปลง
+func (db *DB) buildMerge(table string, columns, types, keys, compare, update []string, rows [][]interface{}) (string, []interface{}) {
+	var names, casts []string
+	for i, c := range columns {
+		names = append(names, db.quote(c))
+		if types[i] != "" {
+			casts = append(casts, fmt.Sprintf("CAST(v.%s AS %s) AS %s", db.quote(c), types[i], db.quote(c)))
+		} else {
+			casts = append(casts, "v."+db.quote(c))
+		}
+	}
+
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ")"
+	var values []string
+	var args []interface{}
+	for _, row := range rows {
+		values = append(values, placeholder)
+		args = append(args, row...)
+	}
+
+	var keyMatch, existsMatch []string
+	for _, k := range keys {
+		keyMatch = append(keyMatch, fmt.Sprintf("t.%s = s.%s", db.quote(k), db.quote(k)))
+		existsMatch = append(existsMatch, fmt.Sprintf("x.%s = src.%s", db.quote(k), db.quote(k)))
+	}
+
+	changed := "1 = 0"
+	if len(compare) > 0 {
+		var srcCols, dstCols []string
+		for _, c := range compare {
+			srcCols = append(srcCols, "src."+db.quote(c))
+			dstCols = append(dstCols, "x."+db.quote(c))
+		}
+		changed = fmt.Sprintf("EXISTS (SELECT %s EXCEPT SELECT %s)", strings.Join(srcCols, ", "), strings.Join(dstCols, ", "))
+	}
+
+	var sets, insertValues []string
+	for _, c := range update {
+		sets = append(sets, fmt.Sprintf("t.%s = s.%s", db.quote(c), db.quote(c)))
+	}
+	for _, c := range columns {
+		insertValues = append(insertValues, "s."+db.quote(c))
+	}
+
+	table = db.quoteTable(table)
+	query := fmt.Sprintf(`MERGE INTO %s WITH (HOLDLOCK) AS t
+USING (
+	SELECT src.*, CASE
+		WHEN NOT EXISTS (SELECT 1 FROM %s x WHERE %s) THEN 'inserted'
+		WHEN EXISTS (SELECT 1 FROM %s x WHERE %s AND %s) THEN 'updated'
+		ELSE 'unchanged' END AS change_kind
+	FROM (SELECT %s FROM (VALUES %s) AS v(%s)) AS src
+) AS s
+ON %s
+WHEN MATCHED THEN UPDATE SET %s
+WHEN NOT MATCHED BY TARGET THEN INSERT (%s) VALUES (%s)
+OUTPUT s.change_kind;`,
+		table,
+		table, strings.Join(existsMatch, " AND "),
+		table, strings.Join(existsMatch, " AND "), changed,
+		strings.Join(casts, ", "), strings.Join(values, ", "), strings.Join(names, ", "),
+		strings.Join(keyMatch, " AND "),
+		strings.Join(sets, ", "),
+		strings.Join(names, ", "), strings.Join(insertValues, ", "))
+
+	return query, args
+}
+
+// parseSchema คืน schema ของ model ใน records
+func (db *DB) parseSchema(records interface{}) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db.DB}
+	if err := stmt.Parse(records); err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่านโครงสร้างตารางได้: %v", err)
+	}
+	return stmt.Schema, nil
+}
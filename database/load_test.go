@@ -0,0 +1,141 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testColumns คือคอลัมน์ของตารางทดสอบ (เหมือนตารางของ feed ที่กำหนดผ่าน schema)
+var testColumns = []Column{
+	{Name: "doc_no", Type: TypeText, Size: 50},
+	{Name: "item_id", Type: TypeText, Size: 50},
+	{Name: "qty", Type: TypeDecimal},
+	{Name: "source_file", Type: TypeText, Size: 255},
+	{Name: "created_at", Type: TypeDateTime},
+}
+
+// testRow คือแถวในตารางทดสอบที่อ่านกลับมาเพื่อตรวจสอบ
+type testRow struct {
+	DocNo      string
+	ItemID     string
+	Qty        float64
+	SourceFile string
+}
+
+// loadTestRows บันทึก rows ด้วย Loader ใหม่ของ mode ทีละช่วงตาม chunks แล้วคืนผลรวม
+func loadTestRows(t *testing.T, db *DB, mode, sourceFile string, chunks ...[]testRow) LoadResult {
+	t.Helper()
+	loader := db.NewTableLoader("feed_test", testColumns, mode, []string{"doc_no", "item_id"})
+	for _, chunk := range chunks {
+		var rows [][]interface{}
+		for _, r := range chunk {
+			rows = append(rows, []interface{}{r.DocNo, r.ItemID, r.Qty, sourceFile, time.Now()})
+		}
+		if err := loader.LoadRows(rows); err != nil {
+			t.Fatalf("LoadRows (%s): %v", mode, err)
+		}
+	}
+	return loader.Result()
+}
+
+// readTestRows คืนทุกแถวในตารางทดสอบเรียงตาม doc_no, item_id
+func readTestRows(t *testing.T, db *DB) []testRow {
+	t.Helper()
+	var rows []testRow
+	err := db.Raw("SELECT doc_no, item_id, qty, source_file FROM feed_test ORDER BY doc_no, item_id, source_file").Scan(&rows).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestTableLoaderModes(t *testing.T) {
+	existing := []testRow{
+		{DocNo: "A", ItemID: "1", Qty: 1},
+		{DocNo: "A", ItemID: "2", Qty: 2},
+		{DocNo: "B", ItemID: "1", Qty: 3},
+	}
+	// ไฟล์ที่สองมี A ทั้งสองช่วง เพื่อตรวจว่าโหมด replace ไม่ลบแถวที่บันทึกจากช่วงก่อนหน้า
+	incoming := [][]testRow{
+		{{DocNo: "A", ItemID: "1", Qty: 1}},
+		{{DocNo: "A", ItemID: "2", Qty: 5}, {DocNo: "C", ItemID: "1", Qty: 7}},
+	}
+
+	tests := []struct {
+		mode   string
+		result LoadResult
+		rows   []testRow
+	}{
+		{
+			mode:   LoadModeAppend,
+			result: LoadResult{Mode: LoadModeAppend, Inserted: 3},
+			rows: []testRow{
+				{"A", "1", 1, "first.csv"},
+				{"A", "1", 1, "second.csv"},
+				{"A", "2", 2, "first.csv"},
+				{"A", "2", 5, "second.csv"},
+				{"B", "1", 3, "first.csv"},
+				{"C", "1", 7, "second.csv"},
+			},
+		},
+		{
+			mode:   LoadModeUpsert,
+			result: LoadResult{Mode: LoadModeUpsert, Inserted: 1, Updated: 1, Unchanged: 1},
+			rows: []testRow{
+				{"A", "1", 1, "second.csv"},
+				{"A", "2", 5, "second.csv"},
+				{"B", "1", 3, "first.csv"},
+				{"C", "1", 7, "second.csv"},
+			},
+		},
+		{
+			mode:   LoadModeReplace,
+			result: LoadResult{Mode: LoadModeReplace, Inserted: 3, Deleted: 2},
+			rows: []testRow{
+				{"A", "1", 1, "second.csv"},
+				{"A", "2", 5, "second.csv"},
+				{"B", "1", 3, "first.csv"},
+				{"C", "1", 7, "second.csv"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			db := openTestSQLite(t)
+			if err := db.EnsureTable("feed_test", testColumns); err != nil {
+				t.Fatal(err)
+			}
+			loadTestRows(t, db, LoadModeAppend, "first.csv", existing)
+
+			err := db.RunInTransaction(func(tx *DB) error {
+				result := loadTestRows(t, tx, tt.mode, "second.csv", incoming...)
+				if result != tt.result {
+					t.Errorf("Result() = %+v ต้องการ %+v", result, tt.result)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := readTestRows(t, db); !reflect.DeepEqual(got, tt.rows) {
+				t.Errorf("ข้อมูลในตาราง = %+v ต้องการ %+v", got, tt.rows)
+			}
+		})
+	}
+}
+
+func TestTableLoaderReplaceUnknownKey(t *testing.T) {
+	db := openTestSQLite(t)
+	if err := db.EnsureTable("feed_test", testColumns); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := db.NewTableLoader("feed_test", testColumns, LoadModeReplace, []string{"DocNo"})
+	err := loader.LoadRows([][]interface{}{{"A", "1", 1.0, "x.csv", time.Now()}})
+	if err == nil {
+		t.Errorf("LoadRows ต้องคืน error เมื่อ key ไม่ตรงกับชื่อคอลัมน์")
+	}
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrLockNotAcquired หมายถึงมี instance อื่นถือ lock อยู่และรอจนหมดเวลาแล้ว
+var ErrLockNotAcquired = errors.New("มี instance อื่นกำลังประมวลผลอยู่")
+
+// RunLock คือ lock ระดับฐานข้อมูลที่ป้องกันไม่ให้หลาย instance ประมวลผลพร้อมกัน
+// (sp_getapplock ใน SQL Server, advisory lock ใน PostgreSQL, GET_LOCK ใน MySQL)
+// lock ผูกกับ session ของ connection เฉพาะ ถ้าโปรเซสหยุดทำงานหรือ connection หลุดฐานข้อมูลจะปล่อย lock ให้เอง
+// SQLite ใช้ exclusive transaction บนไฟล์ lock ข้างไฟล์ฐานข้อมูล ซึ่งระบบปฏิบัติการจะปล่อยให้เองเมื่อโปรเซสหยุดทำงาน
+type RunLock struct {
+	conn    *sql.Conn
+	name    string
+	release string  // คำสั่งปล่อย lock ที่รับ name เป็น parameter
+	lockDB  *sql.DB // ฐานข้อมูลของไฟล์ lock (เฉพาะ SQLite)
+}
+
+// AcquireRunLock ขอ lock ชื่อ name โดยรอได้ไม่เกิน timeout (0 = ไม่รอ)
+// คืน ErrLockNotAcquired ถ้า instance อื่นถือ lock อยู่
+func (db *DB) AcquireRunLock(name string, timeout time.Duration) (*RunLock, error) {
+	if db.driver() == DriverSQLite {
+		return db.acquireSQLite(name, timeout)
+	}
+
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถรับ underlying database connection ได้: %v", err)
+	}
+
+	ctx := db.Statement.Context
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถเปิด connection สำหรับ lock ได้: %v", err)
+	}
+
+	lock := &RunLock{conn: conn, name: name}
+	var acquired bool
+	switch db.driver() {
+	case DriverPostgres:
+		acquired, err = lock.acquirePostgres(ctx, timeout)
+	case DriverMySQL:
+		acquired, err = lock.acquireMySQL(ctx, timeout)
+	default:
+		acquired, err = lock.acquireSQLServer(ctx, timeout)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ไม่สามารถขอ lock %s ได้: %v", name, err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, fmt.Errorf("%w (lock %s)", ErrLockNotAcquired, name)
+	}
+	return lock, nil
+}
+
+// acquireSQLServer ขอ lock ด้วย sp_getapplock
+func (l *RunLock) acquireSQLServer(ctx context.Context, timeout time.Duration) (bool, error) {
+	var status int
+	query := `DECLARE @status int;
+EXEC @status = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = @p2;
+SELECT @status;`
+	if err := l.conn.QueryRowContext(ctx, query, l.name, timeout.Milliseconds()).Scan(&status); err != nil {
+		return false, err
+	}
+	l.release = "EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'"
+
+	// 0 = ได้ lock ทันที, 1 = ได้ lock หลังรอ, -1 = หมดเวลา, ค่าอื่นคือข้อผิดพลาด
+	switch {
+	case status >= 0:
+		return true, nil
+	case status == -1:
+		return false, nil
+	default:
+		return false, fmt.Errorf("sp_getapplock คืนค่า %d", status)
+	}
+}
+
+// acquirePostgres ขอ advisory lock ตาม hash ของชื่อ lock
+// ถ้ากำหนด timeout จะรอด้วย lock_timeout ของ session (รหัส 55P03 = หมดเวลา)
+func (l *RunLock) acquirePostgres(ctx context.Context, timeout time.Duration) (bool, error) {
+	l.release = "SELECT pg_advisory_unlock(hashtext($1))"
+
+	var acquired bool
+	if timeout <= 0 {
+		err := l.conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", l.name).Scan(&acquired)
+		return acquired, err
+	}
+
+	if _, err := l.conn.ExecContext(ctx, fmt.Sprintf("SET lock_timeout = %d", timeout.Milliseconds())); err != nil {
+		return false, err
+	}
+	defer l.conn.ExecContext(context.Background(), "RESET lock_timeout")
+
+	_, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtext($1))", l.name)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "55P03" {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// acquireMySQL ขอ lock ด้วย GET_LOCK ซึ่งรับ timeout เป็นวินาที (ปัดขึ้น)
+func (l *RunLock) acquireMySQL(ctx context.Context, timeout time.Duration) (bool, error) {
+	l.release = "SELECT RELEASE_LOCK(?)"
+
+	seconds := int64((timeout + time.Second - 1) / time.Second)
+	var status sql.NullInt64
+	if err := l.conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", l.name, seconds).Scan(&status); err != nil {
+		return false, err
+	}
+	// 1 = ได้ lock, 0 = หมดเวลา, NULL = ข้อผิดพลาด
+	if !status.Valid {
+		return false, errors.New("GET_LOCK คืนค่า NULL")
+	}
+	return status.Int64 == 1, nil
+}
+
+// acquireSQLite ขอ lock ด้วย BEGIN EXCLUSIVE บนไฟล์ <ฐานข้อมูล>.<name>.lock แยกจากไฟล์ข้อมูล
+// เพื่อไม่ให้ lock ขวางการเขียนข้อมูล ฐานข้อมูลในหน่วยความจำใช้ได้โปรเซสเดียวจึงไม่ต้องมี lock
+func (db *DB) acquireSQLite(name string, timeout time.Duration) (*RunLock, error) {
+	var databases []struct {
+		Name string
+		File string
+	}
+	if err := db.Raw("PRAGMA database_list").Scan(&databases).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่านตำแหน่งไฟล์ฐานข้อมูลได้: %v", err)
+	}
+	var path string
+	for _, d := range databases {
+		if d.Name == "main" {
+			path = d.File
+		}
+	}
+	if path == "" {
+		return &RunLock{name: name}, nil
+	}
+
+	file := path + "." + strings.NewReplacer("/", "_", `\`, "_").Replace(name) + ".lock"
+	lockDB, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_busy_timeout=%d", file, timeout.Milliseconds()))
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถเปิดไฟล์ lock %s ได้: %v", file, err)
+	}
+	ctx := db.Statement.Context
+	conn, err := lockDB.Conn(ctx)
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "BEGIN EXCLUSIVE")
+		if err != nil {
+			conn.Close()
+		}
+	}
+	if err != nil {
+		lockDB.Close()
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrBusy {
+			return nil, fmt.Errorf("%w (lock %s)", ErrLockNotAcquired, name)
+		}
+		return nil, fmt.Errorf("ไม่สามารถขอ lock %s ได้: %v", name, err)
+	}
+	return &RunLock{conn: conn, name: name, release: "ROLLBACK", lockDB: lockDB}, nil
+}
+
+// Release ปล่อย lock และปิด connection ของ lock
+func (l *RunLock) Release() error {
+	if l.conn == nil {
+		return nil
+	}
+	if l.lockDB != nil {
+		defer l.lockDB.Close()
+	}
+	defer l.conn.Close()
+
+	_, err := l.conn.ExecContext(context.Background(), l.release, l.name)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถปล่อย lock %s ได้: %v", l.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcquireRunLockSQLite(t *testing.T) {
+	db := openTestSQLite(t)
+
+	lock, err := db.AcquireRunLock("mcmc-run", 0)
+	if err != nil {
+		t.Fatalf("AcquireRunLock: %v", err)
+	}
+
+	// instance อื่นต้องรอจนหมดเวลาแล้วได้ ErrLockNotAcquired
+	start := time.Now()
+	if second, err := db.AcquireRunLock("mcmc-run", 100*time.Millisecond); !errors.Is(err, ErrLockNotAcquired) {
+		if second != nil {
+			second.Release()
+		}
+		t.Fatalf("AcquireRunLock ครั้งที่สอง error = %v ต้องการ ErrLockNotAcquired", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("AcquireRunLock ครั้งที่สองคืนค่าหลัง %s ต้องรอ timeout ก่อน", elapsed)
+	}
+
+	// lock ชื่ออื่นไม่เกี่ยวข้องกัน และการถือ lock ต้องไม่ขวางการเขียนข้อมูล
+	other, err := db.AcquireRunLock("mcmc-other", 0)
+	if err != nil {
+		t.Fatalf("AcquireRunLock ชื่ออื่น: %v", err)
+	}
+	if err := other.Release(); err != nil {
+		t.Errorf("Release ชื่ออื่น: %v", err)
+	}
+	if err := db.Exec("CREATE TABLE lock_write_test (id integer)").Error; err != nil {
+		t.Errorf("เขียนฐานข้อมูลระหว่างถือ lock ไม่ได้: %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	again, err := db.AcquireRunLock("mcmc-run", 0)
+	if err != nil {
+		t.Fatalf("AcquireRunLock หลัง Release: %v", err)
+	}
+	if err := again.Release(); err != nil {
+		t.Errorf("Release: %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package database
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"mcmc/models"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles คือไฟล์ migration ของทุกฐานข้อมูล แยกโฟลเดอร์ตาม driver
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationName คือรูปแบบชื่อไฟล์ migration เช่น 0001_create_tables.up.sql
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// statementSeparator คือบรรทัดที่คั่นแต่ละคำสั่งในไฟล์ migration (เช่นเดียวกับ GO ของ SQL Server)
+var statementSeparator = regexp.MustCompile(`(?im)^\s*GO\s*$`)
+
+// Migration คือการเปลี่ยนแปลงโครงสร้างฐานข้อมูลหนึ่งเวอร์ชัน
+// จากไฟล์ migrations/<driver>/<version>_<name>.up.sql และ .down.sql
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 ของไฟล์ up
+}
+
+// MigrationStatus คือสถานะของ migration หนึ่งเวอร์ชันในฐานข้อมูล
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Modified  bool // ไฟล์ up ถูกแก้ไขหลังจากทำ migration แล้ว
+	Missing   bool // ทำ migration แล้วแต่ไม่มีไฟล์ในโปรแกรมเวอร์ชันนี้
+}
+
+// loadMigrations อ่าน migration ของ driver เรียงตามเวอร์ชัน
+func loadMigrations(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่พบ migration ของฐานข้อมูลชนิด %s: %v", driver, err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("ชื่อไฟล์ migration ไม่ถูกต้อง: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("ไม่สามารถอ่านไฟล์ migration %s ได้: %v", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration เวอร์ชัน %d มีชื่อไม่ตรงกัน (%s, %s)", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s ต้องมีทั้งไฟล์ up และ down", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitStatements แยกคำสั่งในไฟล์ migration ตามบรรทัด GO และข้ามส่วนที่มีแต่ comment
+func splitStatements(script string) []string {
+	var statements []string
+	for _, part := range statementSeparator.Split(script, -1) {
+		hasSQL := false
+		for _, line := range strings.Split(part, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "--") {
+				hasSQL = true
+				break
+			}
+		}
+		if hasSQL {
+			statements = append(statements, strings.TrimSpace(part))
+		}
+	}
+	return statements
+}
+
+// ensureMigrationTable สร้างตาราง schema_migrations ถ้ายังไม่มี
+func (db *DB) ensureMigrationTable() error {
+	if db.Migrator().HasTable(&models.SchemaMigration{}) {
+		return nil
+	}
+	if err := db.Migrator().CreateTable(&models.SchemaMigration{}); err != nil {
+		return fmt.Errorf("ไม่สามารถสร้างตาราง schema_migrations ได้: %v", err)
+	}
+	return nil
+}
+
+// appliedMigrations คืน migration ที่ทำแล้วตามเวอร์ชัน
+func (db *DB) appliedMigrations() (map[int]models.SchemaMigration, error) {
+	if err := db.ensureMigrationTable(); err != nil {
+		return nil, err
+	}
+	var rows []models.SchemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่านตาราง schema_migrations ได้: %v", err)
+	}
+	applied := make(map[int]models.SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// MigrationStatus คืนสถานะของทุก migration รวมถึงเวอร์ชันที่ทำแล้วแต่ไม่มีไฟล์
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(db.driver())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.Modified = row.Checksum != m.Checksum
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, MigrationStatus{Version: row.Version, Name: row.Name, Applied: true, AppliedAt: &appliedAt, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// PendingMigrations คืนจำนวน migration ที่ยังไม่ได้ทำ
+func (db *DB) PendingMigrations() (int, error) {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, status := range statuses {
+		if !status.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// MigrateUp ทำ migration ที่ยังไม่ได้ทำตามลำดับเวอร์ชันจนถึงเวอร์ชัน target (0 = ทั้งหมด)
+// แต่ละเวอร์ชันทำภายใน transaction เดียวกับการบันทึกลง schema_migrations และคืนจำนวนเวอร์ชันที่ทำ
+func (db *DB) MigrateUp(target int) (int, error) {
+	migrations, err := loadMigrations(db.driver())
+	if err != nil {
+		return 0, err
+	}
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if target > 0 && m.Version > target {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		err := db.RunInTransaction(func(tx *DB) error {
+			if err := tx.execScript(m.Up); err != nil {
+				return err
+			}
+			return tx.Create(&models.SchemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				Checksum:  m.Checksum,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("ไม่สามารถทำ migration %04d_%s ได้: %v", m.Version, m.Name, err)
+		}
+		log.Printf("ทำ migration %04d_%s สำเร็จ", m.Version, m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown ย้อน migration ที่ทำล่าสุดจำนวน steps เวอร์ชัน และคืนจำนวนเวอร์ชันที่ย้อน
+func (db *DB) MigrateDown(steps int) (int, error) {
+	migrations, err := loadMigrations(db.driver())
+	if err != nil {
+		return 0, err
+	}
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		err := db.RunInTransaction(func(tx *DB) error {
+			if err := tx.execScript(m.Down); err != nil {
+				return err
+			}
+			return tx.Delete(&models.SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("ไม่สามารถย้อน migration %04d_%s ได้: %v", m.Version, m.Name, err)
+		}
+		log.Printf("ย้อน migration %04d_%s สำเร็จ", m.Version, m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// execScript รันทุกคำสั่งในไฟล์ migration ตามลำดับ
+func (db *DB) execScript(script string) error {
+	for _, statement := range splitStatements(script) {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"mcmc/models"
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "คำสั่งเดียว",
+			script: "CREATE TABLE a (id int);\n",
+			want:   []string{"CREATE TABLE a (id int);"},
+		},
+		{
+			name:   "คั่นด้วย GO",
+			script: "CREATE TABLE a (id int);\nGO\nCREATE VIEW v AS SELECT id FROM a;\n",
+			want:   []string{"CREATE TABLE a (id int);", "CREATE VIEW v AS SELECT id FROM a;"},
+		},
+		{
+			name:   "GO ตัวพิมพ์เล็กและมีช่องว่าง",
+			script: "SELECT 1\n  go  \nSELECT 2",
+			want:   []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:   "ข้ามส่วนที่มีแต่ comment",
+			script: "-- คำอธิบาย\nGO\nSELECT 1\nGO\n-- ท้ายไฟล์\n",
+			want:   []string{"SELECT 1"},
+		},
+		{
+			name:   "comment อยู่กับคำสั่ง",
+			script: "-- สร้างตาราง\nCREATE TABLE a (id int);",
+			want:   []string{"-- สร้างตาราง\nCREATE TABLE a (id int);"},
+		},
+		{
+			name:   "GO ที่เป็นส่วนของชื่อไม่ใช่ตัวคั่น",
+			script: "SELECT goal\nFROM GOALS",
+			want:   []string{"SELECT goal\nFROM GOALS"},
+		},
+		{
+			name:   "ว่าง",
+			script: "\n\n",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.script)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q) = %q ต้องการ %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	var first []Migration
+	for _, driver := range []string{DriverSQLServer, DriverPostgres, DriverMySQL, DriverSQLite} {
+		t.Run(driver, func(t *testing.T) {
+			migrations, err := loadMigrations(driver)
+			if err != nil {
+				t.Fatalf("loadMigrations(%q): %v", driver, err)
+			}
+			if len(migrations) == 0 {
+				t.Fatalf("loadMigrations(%q) ไม่มี migration", driver)
+			}
+			for i, m := range migrations {
+				if m.Version != i+1 {
+					t.Errorf("migration ลำดับที่ %d มีเวอร์ชัน %d ต้องการ %d", i, m.Version, i+1)
+				}
+				if len(splitStatements(m.Up)) == 0 || len(splitStatements(m.Down)) == 0 {
+					t.Errorf("migration %04d_%s ต้องมีคำสั่งทั้งใน up และ down", m.Version, m.Name)
+				}
+				if len(m.Checksum) != 64 {
+					t.Errorf("migration %04d_%s มี checksum %q", m.Version, m.Name, m.Checksum)
+				}
+			}
+
+			// ทุกฐานข้อมูลต้องมี migration เวอร์ชันและชื่อเดียวกัน
+			if first == nil {
+				first = migrations
+				return
+			}
+			if len(migrations) != len(first) {
+				t.Fatalf("มี migration %d เวอร์ชัน ต้องการ %d เท่ากับ %s", len(migrations), len(first), DriverSQLServer)
+			}
+			for i, m := range migrations {
+				if m.Name != first[i].Name {
+					t.Errorf("migration เวอร์ชัน %d ชื่อ %s ต้องการ %s", m.Version, m.Name, first[i].Name)
+				}
+			}
+		})
+	}
+
+	if _, err := loadMigrations("oracle"); err == nil {
+		t.Errorf("loadMigrations(\"oracle\") ต้องคืน error")
+	}
+}
+
+// legacyLogsTable คือตาราง file_processing_logs ของเวอร์ชันก่อน migration (ตาม script/db.sql)
+const legacyLogsTable = `CREATE TABLE file_processing_logs (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    filename NVARCHAR(255) NOT NULL,
+    status NVARCHAR(10) NOT NULL CHECK (status IN ('success', 'failed')),
+    record_count INT NOT NULL DEFAULT 0,
+    error_message NVARCHAR(MAX),
+    created_at DATETIME2 NOT NULL,
+    CONSTRAINT UQ_filename UNIQUE (filename)
+)`
+
+func TestMigrateUpLegacyLogs(t *testing.T) {
+	db := openTestSQLServer(t)
+	migrations, err := loadMigrations(DriverSQLServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// เริ่มจากฐานข้อมูลที่มีแค่ตาราง file_processing_logs เดิม
+	if _, err := db.MigrateDown(len(migrations)); err != nil {
+		t.Fatalf("MigrateDown ทั้งหมด: %v", err)
+	}
+	if err := db.Exec("DROP TABLE IF EXISTS file_processing_logs").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(legacyLogsTable).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec("DROP TABLE IF EXISTS file_processing_logs") })
+	err = db.Exec(`INSERT INTO file_processing_logs (filename, status, record_count, error_message, created_at) VALUES
+		('a.csv', 'success', 3, NULL, SYSDATETIME()),
+		('b.csv', 'failed', 0, N'ผิดพลาด', SYSDATETIME())`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.MigrateUp(0); err != nil {
+		t.Fatalf("MigrateUp กับ file_processing_logs เดิม: %v", err)
+	}
+
+	var attempts []models.FileProcessingAttempt
+	if err := db.Order("filename").Find(&attempts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("ได้ attempt %d แถว ต้องการ 2", len(attempts))
+	}
+	for i, want := range []struct {
+		filename, status, errorMessage string
+		rowsWritten                    int
+	}{
+		{"a.csv", "success", "", 3},
+		{"b.csv", "failed", "ผิดพลาด", 0},
+	} {
+		got := attempts[i]
+		if got.Filename != want.filename || got.Attempt != 1 || got.RunID != "legacy" || got.Status != want.status ||
+			got.RowsWritten != want.rowsWritten || got.ErrorMessage != want.errorMessage ||
+			got.LoadMode != "" || got.InsertedCount != 0 || got.UpdatedCount != 0 || got.UnchangedCount != 0 {
+			t.Errorf("attempt ของ %s = %+v", want.filename, got)
+		}
+	}
+}
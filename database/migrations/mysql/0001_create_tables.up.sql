@@ -0,0 +1,100 @@
+-- ตารางหลักของ mcmc (โครงสร้างเดียวกับ script/db.sql ของ SQL Server)
+-- MySQL commit คำสั่ง DDL ทันที ถ้า migration ล้มเหลวกลางคันต้องตรวจสอบและแก้ไขด้วยมือ
+
+CREATE TABLE IF NOT EXISTS file_processing_attempts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    filename VARCHAR(255) NOT NULL,
+    attempt INT NOT NULL,
+    run_id VARCHAR(64) NOT NULL,
+    host VARCHAR(255),
+    status VARCHAR(10) NOT NULL CHECK (status IN ('running', 'success', 'failed')),
+    started_at DATETIME(3) NOT NULL,
+    finished_at DATETIME(3),
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    rows_read INT NOT NULL DEFAULT 0,
+    rows_written INT NOT NULL DEFAULT 0,
+    rows_rejected INT NOT NULL DEFAULT 0,
+    load_mode VARCHAR(20),
+    inserted_count INT NOT NULL DEFAULT 0,
+    updated_count INT NOT NULL DEFAULT 0,
+    unchanged_count INT NOT NULL DEFAULT 0,
+    error_message LONGTEXT,
+    CONSTRAINT uq_file_processing_attempts_filename_attempt UNIQUE (filename, attempt),
+    INDEX ix_file_processing_attempts_run_id (run_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+GO
+
+CREATE TABLE IF NOT EXISTS batch_processing_logs (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    batch_id VARCHAR(255) NOT NULL,
+    status VARCHAR(10) NOT NULL CHECK (status IN ('success', 'failed')),
+    file_count INT NOT NULL DEFAULT 0,
+    record_count INT NOT NULL DEFAULT 0,
+    error_message LONGTEXT,
+    created_at DATETIME(3) NOT NULL,
+    INDEX ix_batch_processing_logs_batch_id (batch_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+GO
+
+CREATE TABLE IF NOT EXISTS batch_reconciliations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    batch_id VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('matched', 'mismatched')),
+    expected_header_count INT NOT NULL DEFAULT 0,
+    actual_header_count INT NOT NULL DEFAULT 0,
+    rejected_header_count INT NOT NULL DEFAULT 0,
+    expected_item_count INT NOT NULL DEFAULT 0,
+    actual_item_count INT NOT NULL DEFAULT 0,
+    rejected_item_count INT NOT NULL DEFAULT 0,
+    message LONGTEXT,
+    created_at DATETIME(3) NOT NULL,
+    INDEX ix_batch_reconciliations_batch_id (batch_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+GO
+
+CREATE TABLE IF NOT EXISTS saleorder_header (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    doc_no VARCHAR(50) NOT NULL,
+    on_date DATETIME(3),
+    delivery_date DATETIME(3),
+    so_customer_id VARCHAR(50),
+    customer_name VARCHAR(255),
+    status VARCHAR(50),
+    territory_code VARCHAR(50),
+    total_amount DECIMAL(18, 2),
+    total_vat DECIMAL(18, 2),
+    remark LONGTEXT,
+    source_file VARCHAR(255) NOT NULL,
+    created_at DATETIME(3) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+GO
+
+CREATE TABLE IF NOT EXISTS saleorder_item (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    doc_no VARCHAR(50) NOT NULL,
+    item_id VARCHAR(50),
+    product_code VARCHAR(50),
+    so_product_id VARCHAR(50),
+    sku_unit_type_id VARCHAR(50),
+    quantity DECIMAL(18, 2),
+    price DECIMAL(18, 2),
+    amount DECIMAL(18, 2),
+    vat DECIMAL(18, 2),
+    vat_rate DECIMAL(18, 2),
+    item_type VARCHAR(50),
+    order_rank INT,
+    ref_item_id VARCHAR(50),
+    io_number VARCHAR(50),
+    source_file VARCHAR(255) NOT NULL,
+    created_at DATETIME(3) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+GO
+
+CREATE TABLE IF NOT EXISTS saleorder_summary (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    header_count INT,
+    item_count INT,
+    source_file VARCHAR(255) NOT NULL,
+    created_at DATETIME(3) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+ALTER TABLE file_processing_attempts DROP COLUMN sha256;
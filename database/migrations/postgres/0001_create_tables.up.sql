@@ -0,0 +1,102 @@
+-- ตารางหลักของ mcmc (โครงสร้างเดียวกับ script/db.sql ของ SQL Server)
+
+CREATE TABLE IF NOT EXISTS file_processing_attempts (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    filename VARCHAR(255) NOT NULL,
+    attempt INTEGER NOT NULL,
+    run_id VARCHAR(64) NOT NULL,
+    host VARCHAR(255),
+    status VARCHAR(10) NOT NULL CHECK (status IN ('running', 'success', 'failed')),
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    rows_read INTEGER NOT NULL DEFAULT 0,
+    rows_written INTEGER NOT NULL DEFAULT 0,
+    rows_rejected INTEGER NOT NULL DEFAULT 0,
+    load_mode VARCHAR(20),
+    inserted_count INTEGER NOT NULL DEFAULT 0,
+    updated_count INTEGER NOT NULL DEFAULT 0,
+    unchanged_count INTEGER NOT NULL DEFAULT 0,
+    error_message TEXT,
+    CONSTRAINT uq_file_processing_attempts_filename_attempt UNIQUE (filename, attempt)
+);
+GO
+CREATE INDEX IF NOT EXISTS ix_file_processing_attempts_run_id ON file_processing_attempts (run_id);
+GO
+
+CREATE TABLE IF NOT EXISTS batch_processing_logs (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    batch_id VARCHAR(255) NOT NULL,
+    status VARCHAR(10) NOT NULL CHECK (status IN ('success', 'failed')),
+    file_count INTEGER NOT NULL DEFAULT 0,
+    record_count INTEGER NOT NULL DEFAULT 0,
+    error_message TEXT,
+    created_at TIMESTAMPTZ NOT NULL
+);
+GO
+CREATE INDEX IF NOT EXISTS ix_batch_processing_logs_batch_id ON batch_processing_logs (batch_id);
+GO
+
+CREATE TABLE IF NOT EXISTS batch_reconciliations (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    batch_id VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('matched', 'mismatched')),
+    expected_header_count INTEGER NOT NULL DEFAULT 0,
+    actual_header_count INTEGER NOT NULL DEFAULT 0,
+    rejected_header_count INTEGER NOT NULL DEFAULT 0,
+    expected_item_count INTEGER NOT NULL DEFAULT 0,
+    actual_item_count INTEGER NOT NULL DEFAULT 0,
+    rejected_item_count INTEGER NOT NULL DEFAULT 0,
+    message TEXT,
+    created_at TIMESTAMPTZ NOT NULL
+);
+GO
+CREATE INDEX IF NOT EXISTS ix_batch_reconciliations_batch_id ON batch_reconciliations (batch_id);
+GO
+
+CREATE TABLE IF NOT EXISTS saleorder_header (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    doc_no VARCHAR(50) NOT NULL,
+    on_date TIMESTAMPTZ,
+    delivery_date TIMESTAMPTZ,
+    so_customer_id VARCHAR(50),
+    customer_name VARCHAR(255),
+    status VARCHAR(50),
+    territory_code VARCHAR(50),
+    total_amount NUMERIC(18, 2),
+    total_vat NUMERIC(18, 2),
+    remark TEXT,
+    source_file VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+GO
+
+CREATE TABLE IF NOT EXISTS saleorder_item (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    doc_no VARCHAR(50) NOT NULL,
+    item_id VARCHAR(50),
+    product_code VARCHAR(50),
+    so_product_id VARCHAR(50),
+    sku_unit_type_id VARCHAR(50),
+    quantity NUMERIC(18, 2),
+    price NUMERIC(18, 2),
+    amount NUMERIC(18, 2),
+    vat NUMERIC(18, 2),
+    vat_rate NUMERIC(18, 2),
+    item_type VARCHAR(50),
+    order_rank INTEGER,
+    ref_item_id VARCHAR(50),
+    io_number VARCHAR(50),
+    source_file VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+GO
+
+CREATE TABLE IF NOT EXISTS saleorder_summary (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    header_count INTEGER,
+    item_count INTEGER,
+    source_file VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
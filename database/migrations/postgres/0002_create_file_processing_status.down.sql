@@ -0,0 +1 @@
+DROP VIEW IF EXISTS file_processing_status;
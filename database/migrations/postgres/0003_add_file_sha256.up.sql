@@ -0,0 +1,2 @@
+-- SHA-256 ของไฟล์ที่ดาวน์โหลด (hex) ใช้ตรวจสอบย้อนหลังว่าไฟล์ที่ประมวลผลตรงกับไฟล์ต้นฉบับ
+ALTER TABLE file_processing_attempts ADD COLUMN IF NOT EXISTS sha256 VARCHAR(64);
@@ -0,0 +1,12 @@
+-- ลบตารางทั้งหมดที่สร้างใน 0001 (ข้อมูลในตารางจะหายทั้งหมด)
+DROP TABLE IF EXISTS saleorder_summary;
+GO
+DROP TABLE IF EXISTS saleorder_item;
+GO
+DROP TABLE IF EXISTS saleorder_header;
+GO
+DROP TABLE IF EXISTS batch_reconciliations;
+GO
+DROP TABLE IF EXISTS batch_processing_logs;
+GO
+DROP TABLE IF EXISTS file_processing_attempts;
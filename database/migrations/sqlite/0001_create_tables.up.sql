@@ -0,0 +1,103 @@
+-- ตารางหลักของ mcmc (โครงสร้างเดียวกับ script/db.sql ของ SQL Server)
+-- SQLite ใช้สำหรับทดสอบและรันบนเครื่อง ชนิดข้อมูลจึงใช้ตาม type affinity ของ SQLite
+
+CREATE TABLE IF NOT EXISTS file_processing_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    filename VARCHAR(255) NOT NULL,
+    attempt INTEGER NOT NULL,
+    run_id VARCHAR(64) NOT NULL,
+    host VARCHAR(255),
+    status VARCHAR(10) NOT NULL CHECK (status IN ('running', 'success', 'failed')),
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    rows_read INTEGER NOT NULL DEFAULT 0,
+    rows_written INTEGER NOT NULL DEFAULT 0,
+    rows_rejected INTEGER NOT NULL DEFAULT 0,
+    load_mode VARCHAR(20),
+    inserted_count INTEGER NOT NULL DEFAULT 0,
+    updated_count INTEGER NOT NULL DEFAULT 0,
+    unchanged_count INTEGER NOT NULL DEFAULT 0,
+    error_message TEXT,
+    CONSTRAINT uq_file_processing_attempts_filename_attempt UNIQUE (filename, attempt)
+);
+GO
+CREATE INDEX IF NOT EXISTS ix_file_processing_attempts_run_id ON file_processing_attempts (run_id);
+GO
+
+CREATE TABLE IF NOT EXISTS batch_processing_logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    batch_id VARCHAR(255) NOT NULL,
+    status VARCHAR(10) NOT NULL CHECK (status IN ('success', 'failed')),
+    file_count INTEGER NOT NULL DEFAULT 0,
+    record_count INTEGER NOT NULL DEFAULT 0,
+    error_message TEXT,
+    created_at DATETIME NOT NULL
+);
+GO
+CREATE INDEX IF NOT EXISTS ix_batch_processing_logs_batch_id ON batch_processing_logs (batch_id);
+GO
+
+CREATE TABLE IF NOT EXISTS batch_reconciliations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    batch_id VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('matched', 'mismatched')),
+    expected_header_count INTEGER NOT NULL DEFAULT 0,
+    actual_header_count INTEGER NOT NULL DEFAULT 0,
+    rejected_header_count INTEGER NOT NULL DEFAULT 0,
+    expected_item_count INTEGER NOT NULL DEFAULT 0,
+    actual_item_count INTEGER NOT NULL DEFAULT 0,
+    rejected_item_count INTEGER NOT NULL DEFAULT 0,
+    message TEXT,
+    created_at DATETIME NOT NULL
+);
+GO
+CREATE INDEX IF NOT EXISTS ix_batch_reconciliations_batch_id ON batch_reconciliations (batch_id);
+GO
+
+CREATE TABLE IF NOT EXISTS saleorder_header (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    doc_no VARCHAR(50) NOT NULL,
+    on_date DATETIME,
+    delivery_date DATETIME,
+    so_customer_id VARCHAR(50),
+    customer_name VARCHAR(255),
+    status VARCHAR(50),
+    territory_code VARCHAR(50),
+    total_amount DECIMAL(18, 2),
+    total_vat DECIMAL(18, 2),
+    remark TEXT,
+    source_file VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL
+);
+GO
+
+CREATE TABLE IF NOT EXISTS saleorder_item (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    doc_no VARCHAR(50) NOT NULL,
+    item_id VARCHAR(50),
+    product_code VARCHAR(50),
+    so_product_id VARCHAR(50),
+    sku_unit_type_id VARCHAR(50),
+    quantity DECIMAL(18, 2),
+    price DECIMAL(18, 2),
+    amount DECIMAL(18, 2),
+    vat DECIMAL(18, 2),
+    vat_rate DECIMAL(18, 2),
+    item_type VARCHAR(50),
+    order_rank INTEGER,
+    ref_item_id VARCHAR(50),
+    io_number VARCHAR(50),
+    source_file VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL
+);
+GO
+
+CREATE TABLE IF NOT EXISTS saleorder_summary (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    header_count INTEGER,
+    item_count INTEGER,
+    source_file VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL
+);
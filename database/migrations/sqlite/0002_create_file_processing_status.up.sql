@@ -0,0 +1,12 @@
+-- สถานะปัจจุบันของแต่ละไฟล์ (attempt ล่าสุด) พร้อมจำนวนครั้งที่ประมวลผลและเวลาที่สำเร็จล่าสุด
+CREATE VIEW IF NOT EXISTS file_processing_status AS
+SELECT a.filename, a.attempt AS last_attempt, a.status, a.started_at, a.finished_at, a.error_message,
+    s.attempt_count, ls.finished_at AS last_success_at
+FROM file_processing_attempts a
+JOIN (
+    SELECT filename, MAX(attempt) AS last_attempt, COUNT(*) AS attempt_count,
+        MAX(CASE WHEN status = 'success' THEN attempt END) AS last_success_attempt
+    FROM file_processing_attempts
+    GROUP BY filename
+) s ON s.filename = a.filename AND s.last_attempt = a.attempt
+LEFT JOIN file_processing_attempts ls ON ls.filename = s.filename AND ls.attempt = s.last_success_attempt;
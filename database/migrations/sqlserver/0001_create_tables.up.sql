@@ -0,0 +1,138 @@
+-- ตารางหลักของ mcmc (ตรงกับ script/db.sql)
+-- ตารางที่มีอยู่แล้วจะไม่ถูกแก้ไข เพื่อให้ใช้กับฐานข้อมูลที่เคยสร้างด้วย AutoMigrate หรือ script/db.sql ได้
+
+-- AutoMigrate ของเวอร์ชันก่อนสร้างตารางข้อมูลด้วยชื่อพหูพจน์ เปลี่ยนเป็นชื่อมาตรฐาน
+IF OBJECT_ID(N'sale_order_headers', N'U') IS NOT NULL AND OBJECT_ID(N'saleorder_header', N'U') IS NULL
+    EXEC sp_rename N'sale_order_headers', N'saleorder_header';
+GO
+IF OBJECT_ID(N'sale_order_items', N'U') IS NOT NULL AND OBJECT_ID(N'saleorder_item', N'U') IS NULL
+    EXEC sp_rename N'sale_order_items', N'saleorder_item';
+GO
+IF OBJECT_ID(N'sale_order_summaries', N'U') IS NOT NULL AND OBJECT_ID(N'saleorder_summary', N'U') IS NULL
+    EXEC sp_rename N'sale_order_summaries', N'saleorder_summary';
+GO
+
+IF OBJECT_ID(N'file_processing_attempts', N'U') IS NULL
+CREATE TABLE file_processing_attempts (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    filename NVARCHAR(255) NOT NULL,
+    attempt INT NOT NULL,
+    run_id NVARCHAR(64) NOT NULL,
+    host NVARCHAR(255),
+    status NVARCHAR(10) NOT NULL CHECK (status IN ('running', 'success', 'failed')),
+    started_at DATETIME2 NOT NULL,
+    finished_at DATETIME2,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    rows_read INT NOT NULL DEFAULT 0,
+    rows_written INT NOT NULL DEFAULT 0,
+    rows_rejected INT NOT NULL DEFAULT 0,
+    load_mode NVARCHAR(20),
+    inserted_count INT NOT NULL DEFAULT 0,
+    updated_count INT NOT NULL DEFAULT 0,
+    unchanged_count INT NOT NULL DEFAULT 0,
+    error_message NVARCHAR(MAX),
+    CONSTRAINT UQ_file_processing_attempts_filename_attempt UNIQUE (filename, attempt)
+);
+GO
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE object_id = OBJECT_ID(N'file_processing_attempts')
+    AND name IN (N'IX_file_processing_attempts_run_id', N'idx_file_processing_attempts_run_id'))
+CREATE INDEX IX_file_processing_attempts_run_id ON file_processing_attempts (run_id);
+GO
+
+-- ย้ายประวัติจาก file_processing_logs เดิม (หนึ่งแถวต่อไฟล์) มาเป็น attempt
+-- เพื่อให้ไฟล์ที่เคยประมวลผลสำเร็จแล้วไม่ถูกประมวลผลซ้ำ
+-- ตารางเดิมมีเฉพาะคอลัมน์ตาม script/db.sql คอลัมน์อื่นจึงใช้ค่าเริ่มต้น
+IF OBJECT_ID(N'file_processing_logs', N'U') IS NOT NULL AND NOT EXISTS (SELECT 1 FROM file_processing_attempts)
+INSERT INTO file_processing_attempts
+    (filename, attempt, run_id, host, status, started_at, finished_at, duration_ms, bytes,
+     rows_read, rows_written, rows_rejected, load_mode, inserted_count, updated_count, unchanged_count, error_message)
+SELECT filename, ROW_NUMBER() OVER (PARTITION BY filename ORDER BY id), 'legacy', '', status, created_at, created_at, 0, 0,
+    0, record_count, 0, NULL, 0, 0, 0, error_message
+FROM file_processing_logs;
+GO
+
+IF OBJECT_ID(N'batch_processing_logs', N'U') IS NULL
+CREATE TABLE batch_processing_logs (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    batch_id NVARCHAR(255) NOT NULL,
+    status NVARCHAR(10) NOT NULL CHECK (status IN ('success', 'failed')),
+    file_count INT NOT NULL DEFAULT 0,
+    record_count INT NOT NULL DEFAULT 0,
+    error_message NVARCHAR(MAX),
+    created_at DATETIME2 NOT NULL
+);
+GO
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE object_id = OBJECT_ID(N'batch_processing_logs')
+    AND name IN (N'IX_batch_processing_logs_batch_id', N'idx_batch_processing_logs_batch_id'))
+CREATE INDEX IX_batch_processing_logs_batch_id ON batch_processing_logs (batch_id);
+GO
+
+IF OBJECT_ID(N'batch_reconciliations', N'U') IS NULL
+CREATE TABLE batch_reconciliations (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    batch_id NVARCHAR(255) NOT NULL,
+    status NVARCHAR(20) NOT NULL CHECK (status IN ('matched', 'mismatched')),
+    expected_header_count INT NOT NULL DEFAULT 0,
+    actual_header_count INT NOT NULL DEFAULT 0,
+    rejected_header_count INT NOT NULL DEFAULT 0,
+    expected_item_count INT NOT NULL DEFAULT 0,
+    actual_item_count INT NOT NULL DEFAULT 0,
+    rejected_item_count INT NOT NULL DEFAULT 0,
+    message NVARCHAR(MAX),
+    created_at DATETIME2 NOT NULL
+);
+GO
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE object_id = OBJECT_ID(N'batch_reconciliations')
+    AND name IN (N'IX_batch_reconciliations_batch_id', N'idx_batch_reconciliations_batch_id'))
+CREATE INDEX IX_batch_reconciliations_batch_id ON batch_reconciliations (batch_id);
+GO
+
+IF OBJECT_ID(N'saleorder_header', N'U') IS NULL
+CREATE TABLE saleorder_header (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    doc_no NVARCHAR(50) NOT NULL,
+    on_date DATETIME2,
+    delivery_date DATETIME2,
+    so_customer_id NVARCHAR(50),
+    customer_name NVARCHAR(255),
+    status NVARCHAR(50),
+    territory_code NVARCHAR(50),
+    total_amount DECIMAL(18, 2),
+    total_vat DECIMAL(18, 2),
+    remark NVARCHAR(MAX),
+    source_file NVARCHAR(255) NOT NULL,
+    created_at DATETIME2 NOT NULL
+);
+GO
+
+IF OBJECT_ID(N'saleorder_item', N'U') IS NULL
+CREATE TABLE saleorder_item (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    doc_no NVARCHAR(50) NOT NULL,
+    item_id NVARCHAR(50),
+    product_code NVARCHAR(50),
+    so_product_id NVARCHAR(50),
+    sku_unit_type_id NVARCHAR(50),
+    quantity DECIMAL(18, 2),
+    price DECIMAL(18, 2),
+    amount DECIMAL(18, 2),
+    vat DECIMAL(18, 2),
+    vat_rate DECIMAL(18, 2),
+    item_type NVARCHAR(50),
+    order_rank INT,
+    ref_item_id NVARCHAR(50),
+    io_number NVARCHAR(50),
+    source_file NVARCHAR(255) NOT NULL,
+    created_at DATETIME2 NOT NULL
+);
+GO
+
+IF OBJECT_ID(N'saleorder_summary', N'U') IS NULL
+CREATE TABLE saleorder_summary (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    header_count INT,
+    item_count INT,
+    source_file NVARCHAR(255) NOT NULL,
+    created_at DATETIME2 NOT NULL
+);
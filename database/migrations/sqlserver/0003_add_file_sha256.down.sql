@@ -0,0 +1,2 @@
+IF COL_LENGTH(N'file_processing_attempts', N'sha256') IS NOT NULL
+ALTER TABLE file_processing_attempts DROP COLUMN sha256;
@@ -0,0 +1,3 @@
+-- SHA-256 ของไฟล์ที่ดาวน์โหลด (hex) ใช้ตรวจสอบย้อนหลังว่าไฟล์ที่ประมวลผลตรงกับไฟล์ต้นฉบับ
+IF COL_LENGTH(N'file_processing_attempts', N'sha256') IS NULL
+ALTER TABLE file_processing_attempts ADD sha256 NVARCHAR(64) NULL;
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// ชนิดข้อมูลของ Column ซึ่งแปลงเป็นชนิดของแต่ละฐานข้อมูลด้วยกฎเดียวกับ models (ดู columnType)
+const (
+	TypeText     = "text"
+	TypeDecimal  = "decimal" // decimal(18,4)
+	TypeInteger  = "integer"
+	TypeDateTime = "datetime"
+)
+
+// Column คือคอลัมน์ของตารางที่ไม่มี model (ใช้กับ feed ที่กำหนดผ่าน schema)
+type Column struct {
+	Name string
+	Type string // TypeText, TypeDecimal, TypeInteger หรือ TypeDateTime
+	Size int    // ความยาวสูงสุดของ TypeText (0 = ไม่จำกัด)
+}
+
+// maxInsertRows คือจำนวนแถวสูงสุดต่อคำสั่ง INSERT ... VALUES ที่ SQL Server ยอมรับ
+const maxInsertRows = 1000
+
+// idColumns คือคอลัมน์ primary key ที่เพิ่มให้ตารางของ feed ตามชนิดฐานข้อมูล
+var idColumns = map[string]string{
+	DriverSQLServer: "bigint IDENTITY(1,1) PRIMARY KEY",
+	DriverPostgres:  "bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY",
+	DriverMySQL:     "bigint AUTO_INCREMENT PRIMARY KEY",
+	DriverSQLite:    "integer PRIMARY KEY AUTOINCREMENT",
+}
+
+// EnsureTable สร้างตารางสำหรับ feed ถ้ายังไม่มี (มีคอลัมน์ id เป็น primary key เพิ่มให้)
+// ถ้าตารางมีอยู่แล้วจะไม่แก้ไขโครงสร้าง
+func (db *DB) EnsureTable(table string, columns []Column) error {
+	definitions := []string{db.quote("id") + " " + idColumns[db.driver()]}
+	for _, c := range columns {
+		definitions = append(definitions, db.quote(c.Name)+" "+db.columnType(c)+" NULL")
+	}
+
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", db.quoteTable(table), strings.Join(definitions, ", "))
+	var args []interface{}
+	if db.driver() == DriverSQLServer {
+		query = fmt.Sprintf("IF OBJECT_ID(?, N'U') IS NULL CREATE TABLE %s (%s)",
+			db.quoteTable(table), strings.Join(definitions, ", "))
+		args = append(args, table)
+	}
+	if err := db.Exec(query, args...).Error; err != nil {
+		return fmt.Errorf("ไม่สามารถสร้างตาราง %s ได้: %v", table, err)
+	}
+	return nil
+}
+
+// columnType คืนชนิดข้อมูลของคอลัมน์ c ในฐานข้อมูลที่เชื่อมต่ออยู่
+func (db *DB) columnType(c Column) string {
+	field := &schema.Field{DataType: schema.String, Size: c.Size}
+	switch c.Type {
+	case TypeDecimal:
+		field = &schema.Field{DataType: schema.Float, Precision: 18, Scale: 4}
+	case TypeInteger:
+		field = &schema.Field{DataType: schema.Int, Size: 32}
+	case TypeDateTime:
+		field = &schema.Field{DataType: schema.Time}
+	}
+	return db.Dialector.DataTypeOf(field)
+}
+
+// DeleteTableRowsBySourceFile ลบแถวในตาราง table ที่บันทึกจากไฟล์ sourceFile
+func (db *DB) DeleteTableRowsBySourceFile(table, sourceFile string) (int, error) {
+	deleted, err := db.deleteIn(table, "source_file", []interface{}{sourceFile})
+	if err != nil {
+		return 0, fmt.Errorf("ไม่สามารถลบข้อมูลของไฟล์ %s ในตาราง %s ได้: %v", sourceFile, table, err)
+	}
+	return deleted, nil
+}
+
+// LoadRows บันทึก rows หนึ่งช่วงลงตารางของ Loader โดย rows[i][j] คือค่าของคอลัมน์ที่ j
+// ในโหมด replace แถวเดิมของแต่ละ key จะถูกลบเพียงครั้งแรกที่พบ (เช่นเดียวกับ Load)
+func (l *Loader) LoadRows(rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(l.columns))
+	types := make([]string, len(l.columns))
+	for i, c := range l.columns {
+		names[i] = c.Name
+		types[i] = l.db.columnType(c)
+	}
+
+	var result LoadResult
+	var err error
+	switch l.mode {
+	case LoadModeAppend:
+		err = l.db.insertRows(l.table, names, rows)
+		result.Inserted = len(rows)
+
+	case LoadModeUpsert:
+		err = l.db.RunInTransaction(func(tx *DB) error {
+			var err error
+			result, err = tx.mergeRows(l.table, names, types, l.keys, rows)
+			return err
+		})
+
+	case LoadModeReplace:
+		err = l.db.RunInTransaction(func(tx *DB) error {
+			deleted, err := tx.deleteRows(l.table, names, l.keys[0], rows, l.replaced)
+			if err != nil {
+				return err
+			}
+			if err := tx.insertRows(l.table, names, rows); err != nil {
+				return err
+			}
+			result.Deleted = deleted
+			result.Inserted = len(rows)
+			return nil
+		})
+
+	default:
+		err = fmt.Errorf("ไม่รู้จักวิธีบันทึกข้อมูล: %s", l.mode)
+	}
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกข้อมูลลงตาราง %s ได้: %v", l.table, err)
+	}
+
+	l.result.add(result)
+	return nil
+}
+
+// insertRows insert rows ทีละช่วงตามจำนวน parameter และจำนวนแถวสูงสุดต่อคำสั่ง
+func (db *DB) insertRows(table string, columns []string, rows [][]interface{}) error {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = db.quote(c)
+	}
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ")"
+
+	chunkSize := batchSize(len(columns))
+	for start := 0; start < len(rows); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		var values []string
+		var args []interface{}
+		for _, row := range rows[start:end] {
+			values = append(values, placeholder)
+			args = append(args, row...)
+		}
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+			db.quoteTable(table), strings.Join(quoted, ", "), strings.Join(values, ", "))
+		if err := db.Exec(query, args...).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteRows ลบแถวเดิมในตารางที่มีค่าคอลัมน์ column ตรงกับค่าใน rows
+// ค่าที่อยู่ใน done แล้วจะถูกข้าม และค่าที่ลบแล้วจะถูกเพิ่มลงใน done
+func (db *DB) deleteRows(table string, columns []string, column string, rows [][]interface{}, done map[interface{}]bool) (int, error) {
+	idx := -1
+	for i, c := range columns {
+		if c == column {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return 0, fmt.Errorf("ไม่พบคอลัมน์ %s ในตาราง %s", column, table)
+	}
+
+	var values []interface{}
+	for _, row := range rows {
+		if !done[row[idx]] {
+			done[row[idx]] = true
+			values = append(values, row[idx])
+		}
+	}
+
+	return db.deleteIn(table, column, values)
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxUnionRows คือจำนวนแถวสูงสุดต่อคำสั่งของ buildClassify (SQLite จำกัด SELECT ใน UNION ALL ไว้ที่ 500)
+const maxUnionRows = 500
+
+// upsertChunk บันทึก rows หนึ่งช่วงตาม natural key สำหรับฐานข้อมูลที่ไม่มีคำสั่ง MERGE (postgres, mysql, sqlite)
+// ตรวจสอบผลของทุกแถวก่อนด้วยคำสั่งเดียวเช่นเดียวกับ buildMerge แล้ว insert แถวใหม่ทีละช่วง
+// และอัปเดตแถวที่มีอยู่แล้วทีละแถว (ควรเรียกภายใน transaction)
+func (db *DB) upsertChunk(table string, columns, types, keys, compare, update []string, rows [][]interface{}) ([]string, error) {
+	query, args := db.buildClassify(table, columns, types, keys, compare, rows)
+	sqlRows, err := db.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	kinds := make([]string, len(rows))
+	for sqlRows.Next() {
+		var n int
+		var kind string
+		if err := sqlRows.Scan(&n, &kind); err != nil {
+			sqlRows.Close()
+			return nil, err
+		}
+		kinds[n] = kind
+	}
+	err = sqlRows.Err()
+	sqlRows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	index := map[string]int{}
+	for i, c := range columns {
+		index[c] = i
+	}
+	var sets, match []string
+	for _, c := range update {
+		sets = append(sets, db.quote(c)+" = ?")
+	}
+	for _, k := range keys {
+		match = append(match, db.quote(k)+" = ?")
+	}
+	updateQuery := fmt.Sprintf("UPDATE %s SET %s WHERE %s",
+		db.quoteTable(table), strings.Join(sets, ", "), strings.Join(match, " AND "))
+
+	var inserts [][]interface{}
+	for i, row := range rows {
+		if kinds[i] == "inserted" {
+			inserts = append(inserts, row)
+			continue
+		}
+		if len(update) == 0 {
+			continue
+		}
+
+		var args []interface{}
+		for _, c := range update {
+			args = append(args, row[index[c]])
+		}
+		for _, k := range keys {
+			args = append(args, row[index[k]])
+		}
+		if err := db.Exec(updateQuery, args...).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := db.insertRows(table, columns, inserts); err != nil {
+		return nil, err
+	}
+	return kinds, nil
+}
+
+// buildClassify สร้างคำสั่งที่คืนลำดับของแต่ละแถวใน rows คู่กับผล (inserted, updated หรือ unchanged)
+// โดยเปรียบเทียบกับข้อมูลเดิมก่อนบันทึก ค่าจากไฟล์ถูก CAST ตาม castParam ก่อนเปรียบเทียบ
+func (db *DB) buildClassify(table string, columns, types, keys, compare []string, rows [][]interface{}) (string, []interface{}) {
+	names := []string{db.quote("row_index")}
+	for _, c := range columns {
+		names = append(names, db.quote(c))
+	}
+
+	var params []string
+	for _, t := range types {
+		params = append(params, db.castParam(t))
+	}
+	placeholder := strings.Join(params, ", ")
+
+	var selects []string
+	var args []interface{}
+	for i, row := range rows {
+		selects = append(selects, fmt.Sprintf("SELECT %d, %s", i, placeholder))
+		args = append(args, row...)
+	}
+
+	var keyMatch []string
+	for _, k := range keys {
+		keyMatch = append(keyMatch, fmt.Sprintf("x.%s = src.%s", db.quote(k), db.quote(k)))
+	}
+
+	changed := "1 = 0"
+	if len(compare) > 0 {
+		var conditions []string
+		for _, c := range compare {
+			conditions = append(conditions, db.isDistinct("src."+db.quote(c), "x."+db.quote(c)))
+		}
+		changed = strings.Join(conditions, " OR ")
+	}
+
+	table = db.quoteTable(table)
+	query := fmt.Sprintf(`WITH src (%s) AS (%s)
+SELECT src.%s, CASE
+	WHEN NOT EXISTS (SELECT 1 FROM %s x WHERE %s) THEN 'inserted'
+	WHEN EXISTS (SELECT 1 FROM %s x WHERE %s AND (%s)) THEN 'updated'
+	ELSE 'unchanged' END
+FROM src`,
+		strings.Join(names, ", "), strings.Join(selects, " UNION ALL "),
+		db.quote("row_index"),
+		table, strings.Join(keyMatch, " AND "),
+		table, strings.Join(keyMatch, " AND "), changed)
+
+	return query, args
+}
+
+// castParam คืน placeholder ที่ CAST เป็นชนิด typ ของคอลัมน์ปลายทาง
+// PostgreSQL ต้องรู้ชนิดของทุก parameter ใน UNION ส่วน MySQL CAST ได้เฉพาะบางชนิดจึงใช้กับ decimal
+// เพื่อไม่ให้ทศนิยมที่ถูกปัดนับเป็นการเปลี่ยนแปลง (SQLite เก็บค่าตามที่ส่งไปจึงไม่ต้อง CAST)
+func (db *DB) castParam(typ string) string {
+	switch {
+	case typ == "":
+	case db.driver() == DriverPostgres:
+		return fmt.Sprintf("CAST(? AS %s)", typ)
+	case db.driver() == DriverMySQL && strings.HasPrefix(strings.ToLower(typ), "decimal"):
+		return fmt.Sprintf("CAST(? AS %s)", typ)
+	}
+	return "?"
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openDialect คืน DB ของ driver ที่ใช้สร้างคำสั่ง SQL ได้โดยไม่ต้องเชื่อมต่อกับฐานข้อมูลจริง
+func openDialect(t *testing.T, driver string) *DB {
+	t.Helper()
+	var dialector gorm.Dialector
+	switch driver {
+	case DriverSQLite:
+		return openTestSQLite(t)
+	case DriverPostgres:
+		dialector = postgres.Open("host=127.0.0.1 user=test dbname=test")
+	case DriverMySQL:
+		dialector = mysql.New(mysql.Config{DSN: "test@tcp(127.0.0.1:3306)/test", SkipInitializeWithVersion: true})
+	}
+	gdb, err := gorm.Open(dialector, &gorm.Config{DisableAutomaticPing: true, Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("ไม่สามารถสร้าง dialector %s ได้: %v", driver, err)
+	}
+	return &DB{gdb}
+}
+
+func TestCastParam(t *testing.T) {
+	tests := []struct {
+		driver string
+		typ    string
+		want   string
+	}{
+		{DriverSQLite, "decimal(18,4)", "?"},
+		{DriverPostgres, "", "?"},
+		{DriverPostgres, "numeric(18,4)", "CAST(? AS numeric(18,4))"},
+		{DriverPostgres, "varchar(50)", "CAST(? AS varchar(50))"},
+		{DriverMySQL, "decimal(18,4)", "CAST(? AS decimal(18,4))"},
+		{DriverMySQL, "varchar(50)", "?"},
+	}
+
+	for _, tt := range tests {
+		db := openDialect(t, tt.driver)
+		if got := db.castParam(tt.typ); got != tt.want {
+			t.Errorf("castParam(%q) ของ %s = %q ต้องการ %q", tt.typ, tt.driver, got, tt.want)
+		}
+	}
+}
+
+func TestBuildClassify(t *testing.T) {
+	columns := []string{"doc_no", "qty"}
+	rows := [][]interface{}{{"A", 1.0}, {"B", 2.0}}
+
+	tests := []struct {
+		name     string
+		driver   string
+		types    []string
+		compare  []string
+		contains []string
+	}{
+		{
+			name:    "sqlite",
+			driver:  DriverSQLite,
+			types:   []string{"text", "numeric"},
+			compare: []string{"qty"},
+			contains: []string{
+				`WITH src ("row_index", "doc_no", "qty") AS (SELECT 0, ?, ? UNION ALL SELECT 1, ?, ?)`,
+				`SELECT src."row_index"`,
+				`x."doc_no" = src."doc_no"`,
+				`src."qty" IS NOT x."qty"`,
+			},
+		},
+		{
+			name:    "postgres CAST ทุก parameter",
+			driver:  DriverPostgres,
+			types:   []string{"text", "numeric(18,4)"},
+			compare: []string{"qty"},
+			contains: []string{
+				`SELECT 0, CAST(? AS text), CAST(? AS numeric(18,4)) UNION ALL SELECT 1, CAST(? AS text), CAST(? AS numeric(18,4))`,
+				`src."qty" IS DISTINCT FROM x."qty"`,
+			},
+		},
+		{
+			name:     "ไม่มีคอลัมน์ที่เปรียบเทียบ",
+			driver:   DriverMySQL,
+			types:    []string{"varchar(50)", "decimal(18,4)"},
+			compare:  nil,
+			contains: []string{"WITH src (`row_index`, `doc_no`, `qty`)", "AND (1 = 0)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openDialect(t, tt.driver)
+			query, args := db.buildClassify("feed_test", columns, tt.types, []string{"doc_no"}, tt.compare, rows)
+			for _, want := range tt.contains {
+				if !strings.Contains(query, want) {
+					t.Errorf("คำสั่งไม่มี %q:\n%s", want, query)
+				}
+			}
+			if want := []interface{}{"A", 1.0, "B", 2.0}; !reflect.DeepEqual(args, want) {
+				t.Errorf("args = %v ต้องการ %v", args, want)
+			}
+		})
+	}
+}
+
+func TestUpsertChunk(t *testing.T) {
+	db := openTestSQLite(t)
+	if err := db.EnsureTable("feed_test", testColumns[:4]); err != nil {
+		t.Fatal(err)
+	}
+	existing := [][]interface{}{
+		{"A", "1", 1.0, "first.csv"},
+		{"A", "2", 2.0, "first.csv"},
+		{"D", "1", nil, "first.csv"},
+	}
+	if err := db.insertRows("feed_test", []string{"doc_no", "item_id", "qty", "source_file"}, existing); err != nil {
+		t.Fatal(err)
+	}
+
+	columns := []string{"doc_no", "item_id", "qty", "source_file"}
+	types := []string{"", "", "", ""}
+	keys := []string{"doc_no", "item_id"}
+	rows := [][]interface{}{
+		{"A", "1", 1.0, "second.csv"}, // เหมือนเดิม
+		{"A", "2", 9.0, "second.csv"}, // qty เปลี่ยน
+		{"B", "1", 3.0, "second.csv"}, // แถวใหม่
+		{"D", "1", nil, "second.csv"}, // NULL เท่ากับ NULL
+	}
+
+	kinds, err := db.upsertChunk("feed_test", columns, types, keys, []string{"qty"}, []string{"qty", "source_file"}, rows)
+	if err != nil {
+		t.Fatalf("upsertChunk: %v", err)
+	}
+	if want := []string{"unchanged", "updated", "inserted", "unchanged"}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("upsertChunk = %v ต้องการ %v", kinds, want)
+	}
+
+	var got []struct {
+		DocNo      string
+		ItemID     string
+		Qty        *float64
+		SourceFile string
+	}
+	if err := db.Raw("SELECT doc_no, item_id, qty, source_file FROM feed_test ORDER BY doc_no, item_id").Scan(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("มี %d แถว ต้องการ 4", len(got))
+	}
+	for _, row := range got {
+		if row.SourceFile != "second.csv" {
+			t.Errorf("แถว %s/%s มี source_file %s ต้องการ second.csv", row.DocNo, row.ItemID, row.SourceFile)
+		}
+	}
+	if got[1].Qty == nil || *got[1].Qty != 9 {
+		t.Errorf("qty ของ A/2 = %v ต้องการ 9", got[1].Qty)
+	}
+}
@@ -0,0 +1,33 @@
+package filetime
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	epochMillisPattern = regexp.MustCompile(`(?:^|\D)(\d{13})(?:\D|$)`)
+	datePattern        = regexp.MustCompile(`(?:^|\D)(\d{8})(?:\D|$)`)
+)
+
+// Timestamp คืนเวลาที่ฝังอยู่ในชื่อไฟล์ เช่น saleorder_item_20250514_MCMC-1747195635913-02275.csv
+// ใช้ epoch milliseconds (13 หลัก) ก่อน ถ้าไม่มีใช้วันที่ yyyymmdd และถ้าไม่มีทั้งคู่ใช้ ModTime
+func Timestamp(file os.FileInfo) time.Time {
+	name := file.Name()
+
+	if m := epochMillisPattern.FindStringSubmatch(name); m != nil {
+		if ms, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+			return time.UnixMilli(ms)
+		}
+	}
+
+	if m := datePattern.FindStringSubmatch(name); m != nil {
+		if date, err := time.ParseInLocation("20060102", m[1], time.Local); err == nil {
+			return date
+		}
+	}
+
+	return file.ModTime()
+}
@@ -0,0 +1,41 @@
+package filetime
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// fileInfo คือ os.FileInfo ของไฟล์ทดสอบที่มีแค่ชื่อและเวลาแก้ไข
+type fileInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (f fileInfo) Name() string       { return f.name }
+func (f fileInfo) Size() int64        { return 0 }
+func (f fileInfo) Mode() os.FileMode  { return 0644 }
+func (f fileInfo) ModTime() time.Time { return f.modTime }
+func (f fileInfo) IsDir() bool        { return false }
+func (f fileInfo) Sys() interface{}   { return nil }
+
+func TestTimestamp(t *testing.T) {
+	modTime := time.Date(2025, 1, 2, 3, 4, 5, 0, time.Local)
+
+	tests := []struct {
+		name string
+		want time.Time
+	}{
+		{"saleorder_item_20250514_MCMC-1747195635913-02275.csv", time.UnixMilli(1747195635913)},
+		{"customer_master_20250514.csv", time.Date(2025, 5, 14, 0, 0, 0, 0, time.Local)},
+		{"customer_master_2025-05-14.csv", modTime},
+		{"report_12345678901234.csv", modTime}, // ตัวเลข 14 หลักไม่ใช่ทั้ง epoch และวันที่
+		{"customer_master_20251399.csv", modTime},
+	}
+
+	for _, tt := range tests {
+		if got := Timestamp(fileInfo{name: tt.name, modTime: modTime}); !got.Equal(tt.want) {
+			t.Errorf("Timestamp(%q) = %v ต้องการ %v", tt.name, got, tt.want)
+		}
+	}
+}
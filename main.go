@@ -1,170 +1,490 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"mcmc/config"
 	"mcmc/database"
+	"mcmc/models"
 	"mcmc/process"
 	"mcmc/sftp"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/robfig/cron/v3"
 )
 
+// exit code ของโปรแกรม
+const (
+	exitOK              = 0
+	exitFailure         = 1   // รันครั้งเดียวแล้วมีงานล้มเหลว
+	exitShutdownTimeout = 2   // งานที่ค้างอยู่ไม่จบภายในเวลาที่กำหนดหลังได้รับสัญญาณหยุด
+	exitInterrupted     = 130 // รันครั้งเดียวแต่ถูกสั่งหยุดก่อนทำงานเสร็จ
+)
+
+// rollbackTimeout คือเวลาที่รอให้งานที่ถูกยกเลิก rollback และปิด connection หลังหมด shutdown_timeout
+const rollbackTimeout = 10 * time.Second
+
 func main() {
 	// ตั้งค่า logger
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.Println("เริ่มต้นการประมวลผล...")
 
-	// โหลดการตั้งค่า
-	cfg := config.GetConfig()
-
-	if cfg.Cron.RunOnce {
-		// โหมดรันครั้งเดียว: รันงานทันทีแล้วจบการทำงาน
-		log.Println("รันในโหมดครั้งเดียว")
-		runTask(cfg)
-	} else {
-		// โหมดรันเป็น cron job
-		log.Printf("รันในโหมด cron job ด้วย schedule: %s", cfg.Cron.Schedule)
-		
-		// สร้าง cron scheduler
-		c := cron.New()
-		
-		// ลงทะเบียน cron job
-		_, err := c.AddFunc(cfg.Cron.Schedule, func() {
-			runTask(cfg)
-		})
-		
-		if err != nil {
-			log.Fatalf("ไม่สามารถตั้งค่า cron job ได้: %v", err)
-		}
-		
-		// เริ่มต้น cron scheduler
-		c.Start()
-		
-		// รันงานทันทีหนึ่งครั้งโดยไม่ต้องรอให้ถึงเวลาตาม schedule
-		log.Println("เริ่มรันงานครั้งแรกทันที...")
-		go runTask(cfg)
-		
-		// แสดงเวลาที่จะรันครั้งถัดไป
-		entries := c.Entries()
-		if len(entries) > 0 {
-			nextRun := entries[0].Next
-			log.Printf("ครั้งถัดไปจะรันในเวลา: %s", nextRun.Format("2006-01-02 15:04:05"))
-		}
-		
-		log.Println("กำลังรันเป็น background service (กด Ctrl+C เพื่อหยุดการทำงาน)")
-		
-		// รอไปเรื่อยๆ เพื่อให้โปรแกรมทำงานตลอดไป
-		select {}
-	}
-}
-
-// runTask ทำงานหลักของการดาวน์โหลดและประมวลผลไฟล์
-func runTask(cfg *config.Config) {
-	startTime := time.Now()
-	log.Printf("เริ่มรันงานเวลา %s", startTime.Format("15:04:05"))
-
-	// เชื่อมต่อกับฐานข้อมูล
-	log.Println("กำลังเชื่อมต่อกับฐานข้อมูล...")
-	db, err := database.NewDB(cfg.Database)
+	cmd, args := splitCommand(os.Args[1:])
+	opts, err := parseOptions(cmd, args)
+	if err == flag.ErrHelp {
+		os.Exit(exitOK)
+	}
 	if err != nil {
-		log.Printf("ไม่สามารถเชื่อมต่อกับฐานข้อมูลได้: %v", err)
-		return
+		log.Fatalf("%v", err)
 	}
-	
-	if err := db.MigrateDB(); err != nil {
-		log.Printf("ไม่สามารถทำ migration ได้: %v", err)
-		return
+
+	// โหลดการตั้งค่า (flags ทับค่าจากไฟล์และ environment variable)
+	cfg, err := config.LoadWithOverrides(opts.configPath, opts.overrides)
+	if err != nil {
+		log.Fatalf("ไม่สามารถโหลดการตั้งค่าได้: %v", err)
 	}
-	
-	log.Println("เชื่อมต่อกับฐานข้อมูลสำเร็จ")
 
-	// เชื่อมต่อกับ SFTP
-	log.Println("กำลังเชื่อมต่อกับ SFTP server...")
-	sftpClient, err := sftp.NewClient(cfg.SFTP)
+	if cmd == "" {
+		cmd = cmdServe
+		if cfg.Cron.RunOnce {
+			cmd = cmdRun
+		}
+	}
+
+	// ctx ถูกยกเลิกเมื่อได้รับ SIGINT หรือ SIGTERM เพื่อหยุดเริ่มงานใหม่
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := runCommand(ctx, cmd, cfg, opts)
+	stop()
+	os.Exit(code)
+}
+
+// runOnce รันงานทันทีหนึ่งรอบแล้วคืน exit code
+func runOnce(ctx context.Context, svc *service) int {
+	cfg := svc.cfg
+	log.Println("รันในโหมดครั้งเดียว")
+	done := make(chan error, 1)
+	go func() {
+		done <- svc.run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		log.Printf("ได้รับสัญญาณหยุด รองานที่ค้างอยู่ไม่เกิน %s...", cfg.Cron.ShutdownTimeout)
+		select {
+		case err = <-done:
+		case <-time.After(cfg.Cron.ShutdownTimeout + rollbackTimeout):
+			log.Printf("งานที่ค้างอยู่ไม่จบภายในเวลาที่กำหนด")
+			return exitShutdownTimeout
+		}
+	}
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		log.Printf("หยุดการทำงานก่อนเสร็จ: %v", err)
+		return exitInterrupted
+	case err != nil:
+		log.Printf("การประมวลผลล้มเหลว: %v", err)
+		return exitFailure
+	case ctx.Err() != nil:
+		return exitInterrupted
+	}
+	return exitOK
+}
+
+// serve รันงานตาม cron schedule จนได้รับสัญญาณหยุด แล้วคืน exit code
+func serve(ctx context.Context, svc *service) int {
+	cfg := svc.cfg
+	log.Printf("รันในโหมด cron job ด้วย schedule: %s", cfg.Cron.Schedule)
+
+	// สร้าง cron scheduler
+	c := cron.New()
+
+	// ห่องานด้วย SkipIfStillRunning เพื่อไม่ให้รอบใหม่เริ่มขณะที่รอบก่อนยังไม่เสร็จ
+	// รอบแรกที่รันทันทีใช้ job เดียวกันจึงไม่ซ้อนกับรอบตาม schedule ด้วย
+	var running sync.WaitGroup
+	job := cron.NewChain(cron.SkipIfStillRunning(cron.VerbosePrintfLogger(log.Default()))).Then(cron.FuncJob(func() {
+		if err := svc.run(ctx); err != nil {
+			log.Printf("การประมวลผลรอบนี้ล้มเหลว: %v", err)
+		}
+	}))
+
+	// ลงทะเบียน cron job
+	_, err := c.AddJob(cfg.Cron.Schedule, job)
+
 	if err != nil {
-		log.Printf("ไม่สามารถเชื่อมต่อกับ SFTP server ได้: %v", err)
-		return
+		log.Printf("ไม่สามารถตั้งค่า cron job ได้: %v", err)
+		return exitFailure
+	}
+
+	// เริ่มต้น cron scheduler
+	c.Start()
+
+	// รันงานทันทีหนึ่งครั้งโดยไม่ต้องรอให้ถึงเวลาตาม schedule
+	log.Println("เริ่มรันงานครั้งแรกทันที...")
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		job.Run()
+	}()
+
+	// แสดงเวลาที่จะรันครั้งถัดไป
+	entries := c.Entries()
+	if len(entries) > 0 {
+		nextRun := entries[0].Next
+		log.Printf("ครั้งถัดไปจะรันในเวลา: %s", nextRun.Format("2006-01-02 15:04:05"))
+	}
+
+	log.Println("กำลังรันเป็น background service (กด Ctrl+C เพื่อหยุดการทำงาน)")
+
+	// รอจนได้รับสัญญาณหยุด แล้วหยุดรับรอบใหม่และรองานที่ค้างอยู่
+	<-ctx.Done()
+	log.Printf("ได้รับสัญญาณหยุด หยุดรับรอบใหม่และรองานที่ค้างอยู่ไม่เกิน %s...", cfg.Cron.ShutdownTimeout)
+
+	done := make(chan struct{})
+	go func() {
+		<-c.Stop().Done()
+		running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("หยุดการทำงานเรียบร้อย")
+		return exitOK
+	case <-time.After(cfg.Cron.ShutdownTimeout + rollbackTimeout):
+		log.Printf("งานที่ค้างอยู่ไม่จบภายในเวลาที่กำหนด")
+		return exitShutdownTimeout
 	}
-	defer sftpClient.Close()
-	log.Println("เชื่อมต่อกับ SFTP server สำเร็จ")
+}
 
-	// สร้างโฟลเดอร์สำหรับเก็บไฟล์ที่ดาวน์โหลด
-	if _, err := os.Stat(cfg.App.DownloadDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(cfg.App.DownloadDir, 0755); err != nil {
-			log.Printf("ไม่สามารถสร้างโฟลเดอร์ %s ได้: %v", cfg.App.DownloadDir, err)
+// withGracePeriod คืน context สำหรับงานที่กำลังทำ ซึ่งจะถูกยกเลิกหลังจาก parent ถูกยกเลิกไปแล้ว grace
+// ทำให้งานที่ค้างอยู่มีเวลาทำให้เสร็จ ก่อนจะถูกยกเลิก (transaction จะ rollback)
+func withGracePeriod(parent context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+	go func() {
+		select {
+		case <-parent.Done():
+		case <-ctx.Done():
 			return
 		}
-	}
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			log.Printf("เกินเวลา %s หลังได้รับสัญญาณหยุด ยกเลิกงานที่ค้างอยู่", grace)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
 
-	// ดาวน์โหลดและประมวลผลไฟล์ตาม prefix
-	filesProcessed := 0
-	for _, prefix := range cfg.App.FileTypes {
-		log.Printf("กำลังค้นหาไฟล์ล่าสุดที่ขึ้นต้นด้วย %s...", prefix)
+// processFeeds ประมวลผลไฟล์ใหม่ของทุก feed ใน registry จากเก่าไปใหม่
+// และคืนจำนวนไฟล์ที่สำเร็จและล้มเหลว เมื่อ ctx ถูกยกเลิกจะไม่เริ่มไฟล์ถัดไป
+func processFeeds(ctx context.Context, cfg *config.Config, db *database.DB, attempts *database.AttemptLog, sftpClient *sftp.Client, registry *process.Registry) (int, int) {
+	filesProcessed, filesFailed := 0, 0
+	for _, feed := range registry.Feeds() {
+		log.Printf("กำลังค้นหาไฟล์ของ feed %s (%s)...", feed.Schema.Name, feed.Schema.Pattern)
 
-		// ค้นหาไฟล์ล่าสุดตาม prefix
-		latestFile, err := sftpClient.FindLatestFileByPrefix(prefix)
+		files, err := sftpClient.ListFilesByPattern(feed.Schema.Pattern)
 		if err != nil {
 			log.Printf("ข้อผิดพลาด: %v", err)
-			continue
+			return filesProcessed, filesFailed + 1
 		}
 
-		// ตรวจสอบว่าไฟล์นี้เคยประมวลผลแล้วหรือไม่
-		processed, err := db.CheckFileProcessed(latestFile.Name())
+		processed, err := db.ProcessedFilesByPrefix(feed.Schema.Prefix())
 		if err != nil {
 			log.Printf("ไม่สามารถตรวจสอบประวัติการประมวลผลไฟล์ได้: %v", err)
-		} else if processed {
-			log.Printf("ไฟล์ %s เคยประมวลผลแล้ว ข้ามไปไฟล์ถัดไป", latestFile.Name())
-			continue
+			return filesProcessed, filesFailed + 1
 		}
 
-		log.Printf("พบไฟล์ล่าสุด: %s", latestFile.Name())
+		for _, file := range files {
+			if ctx.Err() != nil {
+				log.Printf("ได้รับสัญญาณหยุด ไม่เริ่มไฟล์ถัดไป")
+				return filesProcessed, filesFailed
+			}
+			if processed[file.Name()] {
+				continue
+			}
 
-		// สร้าง path ของไฟล์
-		remoteFilePath := cfg.SFTP.RemotePath + "/" + latestFile.Name()
-		localFilePath := filepath.Join(cfg.App.DownloadDir, latestFile.Name())
+			err := processFeedFile(cfg, db, attempts, sftpClient, feed, file.Name())
+			if err == nil {
+				filesProcessed++
+				continue
+			}
+			filesFailed++
 
-		// ดาวน์โหลดไฟล์
-		log.Printf("กำลังดาวน์โหลดไฟล์ %s...", latestFile.Name())
-		bytesDownloaded, err := sftpClient.DownloadFile(remoteFilePath, localFilePath)
-		if err != nil {
-			log.Printf("ไม่สามารถดาวน์โหลดไฟล์ได้: %v", err)
-			if err := db.LogFileProcessing(latestFile.Name(), "failed", 0, "ไม่สามารถดาวน์โหลดไฟล์ได้: "+err.Error()); err != nil {
-				log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลได้: %v", err)
+			// ถ้าบันทึกลงฐานข้อมูลไม่ได้ ไฟล์ถัดไปก็จะล้มเหลวเช่นกัน จึงหยุดรอบนี้แล้วรอรอบถัดไป
+			if errors.Is(err, process.ErrPersist) {
+				log.Printf("หยุดการประมวลผลรอบนี้เนื่องจากบันทึกข้อมูลลงฐานข้อมูลไม่ได้")
+				return filesProcessed, filesFailed
 			}
-			continue
 		}
-		log.Printf("ดาวน์โหลดไฟล์ %s สำเร็จ (%d bytes)", latestFile.Name(), bytesDownloaded)
+	}
+	return filesProcessed, filesFailed
+}
 
-		// ประมวลผลไฟล์
-		log.Printf("กำลังประมวลผลไฟล์ %s...", latestFile.Name())
-		recordCount, err := process.ProcessFile(db, localFilePath)
-		if err != nil {
-			log.Printf("ไม่สามารถประมวลผลไฟล์ได้: %v", err)
-			if err := db.LogFileProcessing(latestFile.Name(), "failed", 0, "ไม่สามารถประมวลผลไฟล์ได้: "+err.Error()); err != nil {
-				log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลได้: %v", err)
-			}
-		} else {
-			log.Printf("ประมวลผลไฟล์ %s สำเร็จ (%d รายการ)", latestFile.Name(), recordCount)
-			if err := db.LogFileProcessing(latestFile.Name(), "success", recordCount, ""); err != nil {
-				log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลได้: %v", err)
+// processFeedFile ดาวน์โหลดไฟล์ของ feed แล้วบันทึกลงตารางปลายทางภายใน transaction และจัดการไฟล์ตาม archive config
+func processFeedFile(cfg *config.Config, db *database.DB, attempts *database.AttemptLog, sftpClient *sftp.Client, feed *process.Feed, name string) error {
+	startAttempts(attempts, name)
+	remoteFilePath := cfg.SFTP.RemotePath + "/" + name
+	localFilePath := filepath.Join(cfg.App.DownloadDir, name)
+
+	log.Printf("กำลังดาวน์โหลดไฟล์ %s...", name)
+	downloaded, err := sftpClient.DownloadFile(remoteFilePath, localFilePath)
+	if err != nil {
+		err = fmt.Errorf("ไม่สามารถดาวน์โหลดไฟล์ %s ได้: %v", name, err)
+		log.Printf("ประมวลผลไฟล์ %s ไม่สำเร็จ: %v", name, err)
+		if err := attempts.Finish(name, database.AttemptFailed, database.AttemptStats{}, err.Error()); err != nil {
+			log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลได้: %v", err)
+		}
+		return err
+	}
+	defer func() {
+		// ลบไฟล์ที่ดาวน์โหลดมาเมื่อประมวลผลเสร็จแล้ว
+		if err := os.Remove(localFilePath); err != nil {
+			log.Printf("ไม่สามารถลบไฟล์ได้: %v", err)
+		}
+	}()
+	recordDownload(attempts, name, downloaded)
+
+	err = loadFeedFile(cfg, db, attempts, feed, localFilePath, name, false)
+	archiveFiles(cfg, sftpClient, []string{remoteFilePath}, []string{localFilePath}, err)
+	return err
+}
+
+// loadFeedFile บันทึกไฟล์ของ feed บนเครื่องลงตารางปลายทางภายใน transaction และบันทึกประวัติ
+// replace = true จะลบแถวที่เคยบันทึกจากไฟล์นี้ก่อนใน transaction เดียวกัน
+func loadFeedFile(cfg *config.Config, db *database.DB, attempts *database.AttemptLog, feed *process.Feed, localFilePath, name string, replace bool) error {
+	log.Printf("กำลังประมวลผลไฟล์ %s ของ feed %s...", name, feed.Schema.Name)
+	var result *process.Result
+	err := db.RunInTransaction(func(tx *database.DB) error {
+		if replace {
+			deleted, err := feed.ClearFile(tx, name)
+			if err != nil {
+				return fmt.Errorf("%w: %v", process.ErrPersist, err)
 			}
-			filesProcessed++
+			log.Printf("ลบข้อมูลเดิมของไฟล์ %s แล้ว %d แถว", name, deleted)
 		}
 
-		// ลบไฟล์ที่ดาวน์โหลดมาเมื่อประมวลผลเสร็จแล้ว
-		log.Printf("กำลังลบไฟล์ %s...", localFilePath)
+		var err error
+		result, err = process.ProcessFeedFile(tx, localFilePath, feed, cfg)
+		return err
+	})
+	if err != nil {
+		if !process.IsKnown(err) {
+			err = fmt.Errorf("%w: %v", process.ErrPersist, err)
+		}
+		log.Printf("ประมวลผลไฟล์ %s ไม่สำเร็จ: %v", name, err)
+		if err := attempts.Finish(name, database.AttemptFailed, result.Stats(), err.Error()); err != nil {
+			log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลได้: %v", err)
+		}
+		return err
+	}
+
+	logResult(name, result)
+	if err := attempts.Finish(name, database.AttemptSuccess, result.Stats(), ""); err != nil {
+		log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลได้: %v", err)
+	}
+	return nil
+}
+
+// batchAlreadyProcessed ตรวจสอบว่าชุดไฟล์เคยประมวลผลสำเร็จแล้ว
+// (จากประวัติระดับชุด หรือทุกไฟล์ในชุดเคยประมวลผลสำเร็จแยกกันมาก่อน)
+func batchAlreadyProcessed(db *database.DB, batch *process.Batch, processedFiles map[string]bool) (bool, error) {
+	processed, err := db.CheckBatchProcessed(batch.ID)
+	if err != nil || processed {
+		return processed, err
+	}
+
+	for _, file := range batch.Files {
+		if !processedFiles[file.Name()] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// processBatch ดาวน์โหลดไฟล์ทั้งชุดแล้วบันทึกลงฐานข้อมูลภายใน transaction เดียว และจัดการไฟล์ตาม archive config
+// ถ้าไฟล์ใดล้มเหลวจะ rollback ทั้งชุด และคืน error ที่ตรวจสอบประเภทได้ด้วย errors.Is
+func processBatch(cfg *config.Config, db *database.DB, attempts *database.AttemptLog, sftpClient *sftp.Client, batch *process.Batch) error {
+	log.Printf("กำลังประมวลผลชุดไฟล์ %s...", batch.ID)
+	fileNames := batchFileNames(cfg, batch)
+	startAttempts(attempts, fileNames...)
+
+	// ดาวน์โหลดไฟล์ทั้งชุดก่อน
+	var localFilePaths []string
+	defer removeFiles(&localFilePaths)
+
+	for _, name := range fileNames {
+		remoteFilePath := cfg.SFTP.RemotePath + "/" + name
+		localFilePath := filepath.Join(cfg.App.DownloadDir, name)
+
+		log.Printf("กำลังดาวน์โหลดไฟล์ %s...", name)
+		downloaded, err := sftpClient.DownloadFile(remoteFilePath, localFilePath)
+		if err != nil {
+			return failBatch(db, attempts, batch.ID, fileNames, nil, fmt.Errorf("ไม่สามารถดาวน์โหลดไฟล์ %s ได้: %v", name, err), name)
+		}
+		localFilePaths = append(localFilePaths, localFilePath)
+		recordDownload(attempts, name, downloaded)
+	}
+
+	err := loadBatch(cfg, db, attempts, batch.ID, fileNames, localFilePaths, false)
+	archiveFiles(cfg, sftpClient, remoteFilePaths(cfg, fileNames), localFilePaths, err)
+	return err
+}
+
+// recordDownload บันทึกขนาดและ SHA-256 ของไฟล์ที่ดาวน์โหลดไว้กับ attempt ปัจจุบัน
+func recordDownload(attempts *database.AttemptLog, name string, downloaded *sftp.Download) {
+	attempts.AddBytes(name, downloaded.Bytes)
+	attempts.SetSHA256(name, downloaded.SHA256)
+	if downloaded.Verified != "" {
+		log.Printf("ดาวน์โหลดไฟล์ %s สำเร็จ (%d bytes, sha256 %s, ตรวจสอบกับไฟล์ %s แล้ว)", name, downloaded.Bytes, downloaded.SHA256, downloaded.Verified)
+		return
+	}
+	log.Printf("ดาวน์โหลดไฟล์ %s สำเร็จ (%d bytes, sha256 %s)", name, downloaded.Bytes, downloaded.SHA256)
+}
+
+// batchFileNames คืนชื่อไฟล์ของชุดตามลำดับของ app.file_types
+func batchFileNames(cfg *config.Config, batch *process.Batch) []string {
+	var fileNames []string
+	for _, prefix := range cfg.App.FileTypes {
+		fileNames = append(fileNames, batch.Files[prefix].Name())
+	}
+	return fileNames
+}
+
+// removeFiles ลบไฟล์ที่ดาวน์โหลดมาเมื่อประมวลผลเสร็จแล้ว
+func removeFiles(paths *[]string) {
+	for _, localFilePath := range *paths {
 		if err := os.Remove(localFilePath); err != nil {
 			log.Printf("ไม่สามารถลบไฟล์ได้: %v", err)
 		} else {
 			log.Printf("ลบไฟล์ %s สำเร็จ", localFilePath)
 		}
 	}
+}
 
-	endTime := time.Now()
-	duration := endTime.Sub(startTime)
-	log.Printf("การประมวลผลเสร็จสิ้น ประมวลผลไฟล์ %d รายการ ใช้เวลา %s", filesProcessed, duration)
-}
\ No newline at end of file
+// failBatch บันทึกว่าทั้งชุดล้มเหลว ทั้งระดับไฟล์และระดับชุด
+// ไฟล์ที่เป็นต้นเหตุจะได้ข้อความของตัวเอง ส่วนไฟล์อื่นบันทึกว่าถูก rollback ตามชุด
+// results[i] คือผลของ fileNames[i] เท่าที่ประมวลผลไปแล้ว (สั้นกว่า fileNames หรือเป็น nil ได้)
+func failBatch(db *database.DB, attempts *database.AttemptLog, batchID string, fileNames []string, results []*process.Result, err error, failedFile string) error {
+	message := err.Error()
+	log.Printf("ประมวลผลชุดไฟล์ %s ไม่สำเร็จ: %s", batchID, message)
+	for i, name := range fileNames {
+		fileMessage := message
+		if failedFile != "" && name != failedFile {
+			fileMessage = fmt.Sprintf("ยกเลิกทั้งชุดเนื่องจากไฟล์ %s ล้มเหลว: %s", failedFile, message)
+		}
+		var result *process.Result
+		if i < len(results) {
+			result = results[i]
+		}
+		if err := attempts.Finish(name, database.AttemptFailed, result.Stats(), fileMessage); err != nil {
+			log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลได้: %v", err)
+		}
+	}
+	if err := db.LogBatchProcessing(batchID, "failed", len(fileNames), 0, message); err != nil {
+		log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลชุดไฟล์ได้: %v", err)
+	}
+	return err
+}
+
+// loadBatch บันทึกไฟล์ทั้งชุดที่อยู่บนเครื่องแล้วลงฐานข้อมูลภายใน transaction เดียว และตรวจสอบกับ summary ก่อน commit
+// replace = true จะลบข้อมูลที่เคยบันทึกจากไฟล์เหล่านี้ก่อน (ใช้กับการประมวลผลซ้ำ)
+func loadBatch(cfg *config.Config, db *database.DB, attempts *database.AttemptLog, batchID string, fileNames, localFilePaths []string, replace bool) error {
+	results := make([]*process.Result, len(localFilePaths))
+	var reconciliation *models.BatchReconciliation
+	failedFile := ""
+	err := db.RunInTransaction(func(tx *database.DB) error {
+		if replace {
+			for _, name := range fileNames {
+				if err := clearFile(tx, name); err != nil {
+					return err
+				}
+			}
+		}
+
+		for i, localFilePath := range localFilePaths {
+			log.Printf("กำลังประมวลผลไฟล์ %s...", fileNames[i])
+			result, err := process.ProcessFile(tx, localFilePath, cfg)
+			results[i] = result
+			if err != nil {
+				failedFile = fileNames[i]
+				return fmt.Errorf("ไม่สามารถประมวลผลไฟล์ %s ได้: %w", fileNames[i], err)
+			}
+		}
+
+		// ตรวจสอบจำนวนรายการกับ summary ก่อน commit
+		rec, err := process.Reconcile(tx, batchID, results)
+		if err != nil {
+			return fmt.Errorf("%w: ไม่สามารถตรวจสอบจำนวนรายการได้: %v", process.ErrPersist, err)
+		}
+		reconciliation = rec
+		if rec.Status != process.ReconciliationMatched {
+			return fmt.Errorf("%w: %s", process.ErrReconciliation, rec.Message)
+		}
+		return nil
+	})
+
+	// บันทึกผลการตรวจสอบนอก transaction เพื่อให้เก็บไว้แม้ชุดไฟล์ถูก rollback
+	if reconciliation != nil {
+		if err := db.SaveBatchReconciliation(reconciliation); err != nil {
+			log.Printf("ไม่สามารถบันทึกผลการตรวจสอบจำนวนรายการได้: %v", err)
+		}
+	}
+
+	if err != nil {
+		// error ที่ไม่ได้มาจากขั้นตอนประมวลผล (เช่น commit ไม่สำเร็จ) ถือเป็นการบันทึกข้อมูลล้มเหลว
+		if !process.IsKnown(err) {
+			err = fmt.Errorf("%w: %v", process.ErrPersist, err)
+		}
+		return failBatch(db, attempts, batchID, fileNames, results, err, failedFile)
+	}
+
+	totalRecords := 0
+	for i, name := range fileNames {
+		result := results[i]
+		logResult(name, result)
+		if err := attempts.Finish(name, database.AttemptSuccess, result.Stats(), ""); err != nil {
+			log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลได้: %v", err)
+		}
+		totalRecords += result.Load.Total()
+	}
+	if err := db.LogBatchProcessing(batchID, "success", len(fileNames), totalRecords, ""); err != nil {
+		log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลชุดไฟล์ได้: %v", err)
+	}
+
+	log.Printf("ประมวลผลชุดไฟล์ %s สำเร็จ (%d รายการ)", batchID, totalRecords)
+	return nil
+}
+
+// startAttempts เริ่มบันทึกการประมวลผลของไฟล์ names (ถ้าบันทึกไม่ได้จะประมวลผลต่อ)
+// เรียกครั้งเดียวก่อนดาวน์โหลดหรือบันทึกไฟล์ เพราะ loadFile, loadFeedFile และ loadBatch ไม่เริ่มให้
+func startAttempts(attempts *database.AttemptLog, names ...string) {
+	for _, name := range names {
+		if err := attempts.Start(name); err != nil {
+			log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลได้: %v", err)
+		}
+	}
+}
+
+// clearFile ลบข้อมูลที่เคยบันทึกจากไฟล์ saleorder ชื่อ name
+func clearFile(db *database.DB, name string) error {
+	deleted, err := process.ClearFile(db, name)
+	if err != nil {
+		return fmt.Errorf("%w: %v", process.ErrPersist, err)
+	}
+	log.Printf("ลบข้อมูลเดิมของไฟล์ %s แล้ว %d แถว", name, deleted)
+	return nil
+}
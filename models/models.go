@@ -4,59 +4,145 @@ import (
 	"time"
 )
 
-// FileProcessingLog เก็บประวัติการประมวลผลไฟล์
-type FileProcessingLog struct {
-	ID           uint      `gorm:"primaryKey"`
-	Filename     string    `gorm:"uniqueIndex;type:nvarchar(255);not null"` 
-	Status       string    `gorm:"type:nvarchar(10);not null"`
-	RecordCount  int       `gorm:"not null;default:0"`
-	ErrorMessage string    `gorm:"type:nvarchar(max)"`
-	CreatedAt    time.Time `gorm:"type:datetime2;not null"`
+// FileProcessingAttempt เก็บการประมวลผลไฟล์หนึ่งครั้ง (attempt) ตั้งแต่เริ่มจนจบ
+// ไฟล์เดียวกันมีได้หลายแถว เช่น ครั้งที่ล้มเหลวแล้วลองใหม่ หรือการประมวลผลซ้ำ
+type FileProcessingAttempt struct {
+	ID             uint      `gorm:"primaryKey"`
+	Filename       string    `gorm:"uniqueIndex:idx_file_processing_attempts_filename_attempt;size:255;not null"`
+	Attempt        int       `gorm:"uniqueIndex:idx_file_processing_attempts_filename_attempt;not null"`
+	RunID          string    `gorm:"index;size:64;not null"`
+	Host           string    `gorm:"size:255"`
+	Status         string    `gorm:"size:10;not null"` // running, success, failed
+	StartedAt      time.Time `gorm:"not null"`
+	FinishedAt     *time.Time
+	DurationMs     int64  `gorm:"not null;default:0"`
+	Bytes          int64  `gorm:"not null;default:0"`
+	RowsRead       int    `gorm:"not null;default:0"`
+	RowsWritten    int    `gorm:"not null;default:0"`
+	RowsRejected   int    `gorm:"not null;default:0"`
+	LoadMode       string `gorm:"size:20"`
+	InsertedCount  int    `gorm:"not null;default:0"`
+	UpdatedCount   int    `gorm:"not null;default:0"`
+	UnchangedCount int    `gorm:"not null;default:0"`
+	ErrorMessage   string
+	SHA256         string `gorm:"column:sha256;size:64"` // hex ของไฟล์ที่ดาวน์โหลด (ว่าง = ไม่ได้ดาวน์โหลด)
+}
+
+// FileProcessingStatus คือสถานะปัจจุบันของแต่ละไฟล์จาก view file_processing_status
+// (attempt ล่าสุด พร้อมจำนวนครั้งทั้งหมดและเวลาที่สำเร็จล่าสุด)
+type FileProcessingStatus struct {
+	Filename      string
+	LastAttempt   int
+	Status        string
+	StartedAt     time.Time
+	FinishedAt    *time.Time
+	ErrorMessage  string
+	AttemptCount  int
+	LastSuccessAt *time.Time
+}
+
+// TableName คืนชื่อ view ของ FileProcessingStatus
+func (FileProcessingStatus) TableName() string {
+	return "file_processing_status"
+}
+
+// BatchProcessingLog เก็บผลการประมวลผลของชุดไฟล์ที่มี batch ID เดียวกัน
+type BatchProcessingLog struct {
+	ID           uint   `gorm:"primaryKey"`
+	BatchID      string `gorm:"index;size:255;not null"`
+	Status       string `gorm:"size:10;not null"`
+	FileCount    int    `gorm:"not null;default:0"`
+	RecordCount  int    `gorm:"not null;default:0"`
+	ErrorMessage string
+	CreatedAt    time.Time `gorm:"not null"`
+}
+
+// BatchReconciliation เก็บผลการตรวจสอบจำนวนรายการใน summary เทียบกับจำนวนแถวที่บันทึกจากไฟล์ในชุด
+type BatchReconciliation struct {
+	ID                  uint   `gorm:"primaryKey"`
+	BatchID             string `gorm:"index;size:255;not null"`
+	Status              string `gorm:"size:20;not null"`
+	ExpectedHeaderCount int    `gorm:"not null;default:0"`
+	ActualHeaderCount   int    `gorm:"not null;default:0"`
+	RejectedHeaderCount int    `gorm:"not null;default:0"`
+	ExpectedItemCount   int    `gorm:"not null;default:0"`
+	ActualItemCount     int    `gorm:"not null;default:0"`
+	RejectedItemCount   int    `gorm:"not null;default:0"`
+	Message             string
+	CreatedAt           time.Time `gorm:"not null"`
 }
 
 // SaleOrderHeader เก็บข้อมูลหัวเอกสารการขาย
 type SaleOrderHeader struct {
-	ID            uint       `gorm:"primaryKey"`
-	DocNo         string     `gorm:"type:nvarchar(50);not null"`
-	OnDate        *time.Time `gorm:"type:datetime2"`
-	DeliveryDate  *time.Time `gorm:"type:datetime2"`
-	SOCustomerID  string     `gorm:"type:nvarchar(50)"`
-	CustomerName  string     `gorm:"type:nvarchar(255)"`
-	Status        string     `gorm:"type:nvarchar(50)"`
-	TerritoryCode string     `gorm:"type:nvarchar(50)"`
-	TotalAmount   float64    `gorm:"type:decimal(18,2)"`
-	TotalVat      float64    `gorm:"type:decimal(18,2)"`
-	Remark        string     `gorm:"type:nvarchar(max)"`
-	SourceFile    string     `gorm:"type:nvarchar(255);not null"`
-	CreatedAt     time.Time  `gorm:"type:datetime2;not null"`
+	ID            uint   `gorm:"primaryKey"`
+	DocNo         string `gorm:"size:50;not null"`
+	OnDate        *time.Time
+	DeliveryDate  *time.Time
+	SOCustomerID  string  `gorm:"size:50"`
+	CustomerName  string  `gorm:"size:255"`
+	Status        string  `gorm:"size:50"`
+	TerritoryCode string  `gorm:"size:50"`
+	TotalAmount   float64 `gorm:"precision:18;scale:2"`
+	TotalVat      float64 `gorm:"precision:18;scale:2"`
+	Remark        string
+	SourceFile    string    `gorm:"size:255;not null"`
+	CreatedAt     time.Time `gorm:"not null"`
+}
+
+// TableName คืนชื่อตารางตาม script/db.sql
+func (SaleOrderHeader) TableName() string {
+	return "saleorder_header"
 }
 
 // SaleOrderItem เก็บข้อมูลรายการสินค้าในเอกสารการขาย
 type SaleOrderItem struct {
-	ID            uint      `gorm:"primaryKey"`
-	DocNo         string    `gorm:"type:nvarchar(50);not null"`
-	ItemID        string    `gorm:"type:nvarchar(50)"`
-	ProductCode   string    `gorm:"type:nvarchar(50)"`
-	SOProductID   string    `gorm:"type:nvarchar(50)"`
-	SKUUnitTypeID string    `gorm:"type:nvarchar(50)"`
-	Quantity      float64   `gorm:"type:decimal(18,2)"`
-	Price         float64   `gorm:"type:decimal(18,2)"`
-	Amount        float64   `gorm:"type:decimal(18,2)"`
-	Vat           float64   `gorm:"type:decimal(18,2)"`
-	VatRate       float64   `gorm:"type:decimal(18,2)"`
-	ItemType      string    `gorm:"type:nvarchar(50)"`
-	OrderRank     int       
-	RefItemID     string    `gorm:"type:nvarchar(50)"`
-	IONumber      string    `gorm:"type:nvarchar(50)"`
-	SourceFile    string    `gorm:"type:nvarchar(255);not null"`
-	CreatedAt     time.Time `gorm:"type:datetime2;not null"`
+	ID            uint    `gorm:"primaryKey"`
+	DocNo         string  `gorm:"size:50;not null"`
+	ItemID        string  `gorm:"size:50"`
+	ProductCode   string  `gorm:"size:50"`
+	SOProductID   string  `gorm:"size:50"`
+	SKUUnitTypeID string  `gorm:"size:50"`
+	Quantity      float64 `gorm:"precision:18;scale:2"`
+	Price         float64 `gorm:"precision:18;scale:2"`
+	Amount        float64 `gorm:"precision:18;scale:2"`
+	Vat           float64 `gorm:"precision:18;scale:2"`
+	VatRate       float64 `gorm:"precision:18;scale:2"`
+	ItemType      string  `gorm:"size:50"`
+	OrderRank     int
+	RefItemID     string    `gorm:"size:50"`
+	IONumber      string    `gorm:"size:50"`
+	SourceFile    string    `gorm:"size:255;not null"`
+	CreatedAt     time.Time `gorm:"not null"`
+}
+
+// TableName คืนชื่อตารางตาม script/db.sql
+func (SaleOrderItem) TableName() string {
+	return "saleorder_item"
 }
 
 // SaleOrderSummary เก็บข้อมูลสรุปของเอกสารการขาย
 type SaleOrderSummary struct {
-	ID          uint      `gorm:"primaryKey"`
-	HeaderCount int       
-	ItemCount   int       
-	SourceFile  string    `gorm:"type:nvarchar(255);not null"`
-	CreatedAt   time.Time `gorm:"type:datetime2;not null"`
-}
\ No newline at end of file
+	ID          uint `gorm:"primaryKey"`
+	HeaderCount int
+	ItemCount   int
+	SourceFile  string    `gorm:"size:255;not null"`
+	CreatedAt   time.Time `gorm:"not null"`
+}
+
+// TableName คืนชื่อตารางตาม script/db.sql
+func (SaleOrderSummary) TableName() string {
+	return "saleorder_summary"
+}
+
+// SchemaMigration เก็บ migration ที่ทำกับฐานข้อมูลแล้ว (ดู database.MigrateUp)
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	Checksum  string    `gorm:"size:64;not null"` // sha256 ของไฟล์ up ตอนที่ทำ migration
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName คืนชื่อตารางของ SchemaMigration
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"mcmc/archive"
+	"mcmc/config"
+	"mcmc/process"
+	"mcmc/sftp"
+	"path"
+	"time"
+)
+
+// archiveFiles จัดการไฟล์หลังประมวลผลตาม archive config โดยเก็บสำเนาบีบอัดบนเครื่อง
+// แล้วย้ายหรือลบไฟล์บน SFTP server ตามผล err ของการประมวลผล (remoteFilePaths[i] คู่กับ localFilePaths[i])
+// ถ้าล้มเหลวเพราะบันทึกลงฐานข้อมูลไม่ได้จะไม่ทำอะไร เพื่อให้ประมวลผลใหม่ในรอบถัดไป
+// ข้อผิดพลาดระหว่างจัดการไฟล์ถูกบันทึกใน log เท่านั้น เพราะข้อมูลถูกบันทึกเรียบร้อยแล้ว
+func archiveFiles(cfg *config.Config, sftpClient *sftp.Client, remoteFilePaths, localFilePaths []string, err error) {
+	if errors.Is(err, process.ErrPersist) {
+		return
+	}
+
+	outcome, action, dir := archive.Success, cfg.Archive.RemoteOnSuccess, cfg.Archive.RemoteSuccessDir
+	if err != nil {
+		outcome, action, dir = archive.Failure, cfg.Archive.RemoteOnFailure, cfg.Archive.RemoteFailureDir
+	}
+
+	if cfg.Archive.LocalDir != "" {
+		store := archive.Store{Dir: cfg.Archive.LocalDir}
+		for _, localFilePath := range localFilePaths {
+			saved, err := store.Save(localFilePath, outcome, time.Now())
+			if err != nil {
+				log.Printf("ไม่สามารถเก็บสำเนาของไฟล์ได้: %v", err)
+				continue
+			}
+			log.Printf("เก็บสำเนาของไฟล์ไว้ที่ %s", saved)
+		}
+	}
+
+	if action == "keep" {
+		return
+	}
+	for _, remoteFilePath := range withSidecars(sftpClient, remoteFilePaths) {
+		switch action {
+		case "move":
+			target := remoteArchiveDir(cfg, dir)
+			if path.Dir(remoteFilePath) == target {
+				continue
+			}
+			moved, err := sftpClient.MoveFile(remoteFilePath, target)
+			if err != nil {
+				log.Printf("ข้อผิดพลาด: %v", err)
+				continue
+			}
+			log.Printf("ย้ายไฟล์ %s ไปยัง %s บน SFTP server แล้ว", remoteFilePath, moved)
+		case "delete":
+			if err := sftpClient.RemoveFile(remoteFilePath); err != nil {
+				log.Printf("ข้อผิดพลาด: %v", err)
+				continue
+			}
+			log.Printf("ลบไฟล์ %s บน SFTP server แล้ว", remoteFilePath)
+		}
+	}
+}
+
+// withSidecars คืน remoteFilePaths พร้อมไฟล์ checksum (.sha256, .md5) ที่มีอยู่คู่กับแต่ละไฟล์
+// เพื่อให้ไฟล์ checksum ถูกย้ายหรือลบไปพร้อมกับไฟล์ข้อมูล
+func withSidecars(sftpClient *sftp.Client, remoteFilePaths []string) []string {
+	var paths []string
+	for _, remoteFilePath := range remoteFilePaths {
+		paths = append(paths, remoteFilePath)
+		for _, ext := range sftp.SidecarExtensions {
+			exists, err := sftpClient.Exists(remoteFilePath + ext)
+			if err != nil {
+				log.Printf("ข้อผิดพลาด: %v", err)
+				continue
+			}
+			if exists {
+				paths = append(paths, remoteFilePath+ext)
+			}
+		}
+	}
+	return paths
+}
+
+// remoteArchiveDir คืน path ของโฟลเดอร์ dir บน SFTP server (dir ที่ไม่ใช่ absolute path อยู่ใต้ sftp.remote_path)
+func remoteArchiveDir(cfg *config.Config, dir string) string {
+	if path.IsAbs(dir) {
+		return dir
+	}
+	return path.Join(cfg.SFTP.RemotePath, dir)
+}
+
+// remoteDirs คืนโฟลเดอร์บน SFTP server ที่อาจมีไฟล์อยู่ เรียงตามลำดับที่ค้นหา
+// (sftp.remote_path ตามด้วยโฟลเดอร์ที่ย้ายไฟล์ไปหลังประมวลผล)
+func remoteDirs(cfg *config.Config) []string {
+	dirs := []string{cfg.SFTP.RemotePath}
+	for _, dir := range []string{cfg.Archive.RemoteSuccessDir, cfg.Archive.RemoteFailureDir} {
+		if dir == "" {
+			continue
+		}
+		dir = remoteArchiveDir(cfg, dir)
+		if path.Clean(dir) != path.Clean(cfg.SFTP.RemotePath) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// remoteFilePaths คืน path บน SFTP server ของไฟล์ที่ยังอยู่ใน sftp.remote_path
+func remoteFilePaths(cfg *config.Config, names []string) []string {
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = cfg.SFTP.RemotePath + "/" + name
+	}
+	return paths
+}
+
+// pruneArchive ลบสำเนาบนเครื่องที่เก่ากว่า archive.local_retention
+func pruneArchive(cfg *config.Config) {
+	if cfg.Archive.LocalDir == "" {
+		return
+	}
+	store := archive.Store{Dir: cfg.Archive.LocalDir, Retention: cfg.Archive.LocalRetention}
+	removed, err := store.Prune(time.Now())
+	if err != nil {
+		log.Printf("ข้อผิดพลาด: %v", err)
+	}
+	if removed > 0 {
+		log.Printf("ลบสำเนาที่เก่ากว่า %s แล้ว %d ไฟล์", cfg.Archive.LocalRetention, removed)
+	}
+}
@@ -0,0 +1,83 @@
+package process
+
+import (
+	"mcmc/filetime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Batch คือชุดไฟล์ header/item/summary ที่มี batch ID เดียวกัน
+// เช่น saleorder_header_20250514_MCMC-1747195635913-02275.csv มี batch ID เป็น 20250514_MCMC-1747195635913-02275
+type Batch struct {
+	ID        string
+	Files     map[string]os.FileInfo // key คือ prefix ของไฟล์
+	Timestamp time.Time              // เวลาของไฟล์ที่เก่าที่สุดในชุด
+}
+
+// BatchID คืน batch ID จากชื่อไฟล์โดยตัด prefix และนามสกุลออก
+func BatchID(prefix, fileName string) string {
+	id := strings.TrimPrefix(fileName, prefix)
+	return strings.TrimSuffix(id, filepath.Ext(id))
+}
+
+// GroupBatches จัดกลุ่มไฟล์ตาม batch ID และเรียงชุดจากเก่าไปใหม่
+func GroupBatches(prefixes []string, files []os.FileInfo) []*Batch {
+	batches := map[string]*Batch{}
+	for _, file := range files {
+		prefix := matchPrefix(prefixes, file.Name())
+		if prefix == "" {
+			continue
+		}
+
+		id := BatchID(prefix, file.Name())
+		b, ok := batches[id]
+		if !ok {
+			b = &Batch{ID: id, Files: map[string]os.FileInfo{}}
+			batches[id] = b
+		}
+		b.Files[prefix] = file
+
+		ts := filetime.Timestamp(file)
+		if b.Timestamp.IsZero() || ts.Before(b.Timestamp) {
+			b.Timestamp = ts
+		}
+	}
+
+	result := make([]*Batch, 0, len(batches))
+	for _, b := range batches {
+		result = append(result, b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Timestamp.Equal(result[j].Timestamp) {
+			return result[i].Timestamp.Before(result[j].Timestamp)
+		}
+		return result[i].ID < result[j].ID
+	})
+
+	return result
+}
+
+// Missing คืนรายการ prefix ที่ยังไม่มีไฟล์ในชุดนี้
+func (b *Batch) Missing(prefixes []string) []string {
+	var missing []string
+	for _, prefix := range prefixes {
+		if _, ok := b.Files[prefix]; !ok {
+			missing = append(missing, prefix)
+		}
+	}
+	return missing
+}
+
+// matchPrefix คืน prefix ที่ยาวที่สุดที่ตรงกับชื่อไฟล์
+func matchPrefix(prefixes []string, fileName string) string {
+	matched := ""
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(fileName, prefix) && len(prefix) > len(matched) {
+			matched = prefix
+		}
+	}
+	return matched
+}
@@ -0,0 +1,51 @@
+package process
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// fileInfo คือ os.FileInfo ของไฟล์ทดสอบที่มีแค่ชื่อและเวลาแก้ไข
+type fileInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (f fileInfo) Name() string       { return f.name }
+func (f fileInfo) Size() int64        { return 0 }
+func (f fileInfo) Mode() os.FileMode  { return 0644 }
+func (f fileInfo) ModTime() time.Time { return f.modTime }
+func (f fileInfo) IsDir() bool        { return false }
+func (f fileInfo) Sys() interface{}   { return nil }
+
+func TestGroupBatches(t *testing.T) {
+	prefixes := []string{"saleorder_header_", "saleorder_item_", "saleorder_summary_"}
+	files := []os.FileInfo{
+		fileInfo{name: "saleorder_item_20250515_MCMC-1747281600000-00002.csv"},
+		fileInfo{name: "saleorder_header_20250515_MCMC-1747281600000-00002.csv"},
+		fileInfo{name: "saleorder_header_20250514_MCMC-1747195635913-00001.csv"},
+		fileInfo{name: "saleorder_item_20250514_MCMC-1747195635913-00001.csv"},
+		fileInfo{name: "saleorder_summary_20250514_MCMC-1747195635913-00001.csv"},
+		fileInfo{name: "customer_master_20250514.csv"},
+	}
+
+	batches := GroupBatches(prefixes, files)
+	if len(batches) != 2 {
+		t.Fatalf("GroupBatches คืน %d ชุด ต้องการ 2", len(batches))
+	}
+
+	// เรียงจากเก่าไปใหม่ตามเวลาในชื่อไฟล์
+	if batches[0].ID != "20250514_MCMC-1747195635913-00001" || batches[1].ID != "20250515_MCMC-1747281600000-00002" {
+		t.Errorf("ลำดับของชุด = %s, %s", batches[0].ID, batches[1].ID)
+	}
+	if want := time.UnixMilli(1747195635913); !batches[0].Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v ต้องการ %v", batches[0].Timestamp, want)
+	}
+	if missing := batches[0].Missing(prefixes); len(missing) != 0 {
+		t.Errorf("ชุดแรก Missing = %v ต้องการว่าง", missing)
+	}
+	if missing := batches[1].Missing(prefixes); len(missing) != 1 || missing[0] != "saleorder_summary_" {
+		t.Errorf("ชุดที่สอง Missing = %v ต้องการ [saleorder_summary_]", missing)
+	}
+}
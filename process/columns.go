@@ -0,0 +1,106 @@
+package process
+
+import (
+	"fmt"
+	"mcmc/config"
+	"strings"
+)
+
+// columnMapping เก็บตำแหน่งในไฟล์ของแต่ละคอลัมน์ตามกฎ ทำให้ไม่ขึ้นกับลำดับคอลัมน์ที่ vendor ส่งมา
+type columnMapping struct {
+	rules   []ColumnRule
+	indexes []int // indexes[i] คือตำแหน่งในไฟล์ของ rules[i]
+	width   int   // จำนวนคอลัมน์ใน header ของไฟล์
+}
+
+// resolveColumns จับคู่ header ของไฟล์กับกฎตามชื่อคอลัมน์ (ไม่สนตัวพิมพ์และเครื่องหมาย _ - ช่องว่าง)
+// โดยใช้ aliases แปลงชื่อในไฟล์เป็นชื่อมาตรฐานก่อน
+// คืน error ที่ระบุคอลัมน์ที่ขาด ซ้ำ หรือเกินมา (ถ้าไม่อนุญาต) ทั้งหมดในครั้งเดียว
+func resolveColumns(header []string, rules []ColumnRule, cfg config.ColumnsConfig) (*columnMapping, error) {
+	aliases := map[string]string{}
+	for alias, name := range cfg.Aliases {
+		aliases[normalizeColumn(alias)] = normalizeColumn(name)
+	}
+
+	// หาตำแหน่งของแต่ละคอลัมน์ในไฟล์ตามชื่อมาตรฐาน
+	keys := make([]string, len(header))
+	positions := map[string]int{}
+	var duplicates []string
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\uFEFF") // ตัด BOM ที่อาจติดมากับคอลัมน์แรก
+		}
+		key := normalizeColumn(name)
+		if canonical, ok := aliases[key]; ok {
+			key = canonical
+		}
+		keys[i] = key
+		if _, exists := positions[key]; exists {
+			duplicates = append(duplicates, name)
+			continue
+		}
+		positions[key] = i
+	}
+
+	mapping := &columnMapping{rules: rules, indexes: make([]int, len(rules)), width: len(header)}
+	known := map[string]bool{}
+	var missing []string
+	for i, rule := range rules {
+		key := normalizeColumn(rule.Name)
+		known[key] = true
+		idx, ok := positions[key]
+		if !ok {
+			missing = append(missing, rule.Name)
+			continue
+		}
+		mapping.indexes[i] = idx
+	}
+
+	var unknown []string
+	if !cfg.AllowExtra {
+		for i, key := range keys {
+			if !known[key] && positions[key] == i {
+				unknown = append(unknown, header[i])
+			}
+		}
+	}
+
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "ขาดคอลัมน์ "+strings.Join(missing, ", "))
+	}
+	if len(duplicates) > 0 {
+		problems = append(problems, "คอลัมน์ซ้ำ "+strings.Join(duplicates, ", "))
+	}
+	if len(unknown) > 0 {
+		problems = append(problems, "คอลัมน์ที่ไม่รู้จัก "+strings.Join(unknown, ", "))
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSchemaMismatch, strings.Join(problems, "; "))
+	}
+
+	return mapping, nil
+}
+
+// validate ตรวจสอบจำนวนฟิลด์เทียบกับ header และค่าของแต่ละคอลัมน์
+// คืนค่าที่จัดเรียงตามลำดับของกฎแล้ว (values[i] คือค่าของ rules[i])
+func (m *columnMapping) validate(record []string) ([]string, []Violation) {
+	if len(record) != m.width {
+		return nil, []Violation{{
+			Column:  "*",
+			Message: fmt.Sprintf("จำนวนฟิลด์ไม่ตรงกับ header: คาดหวัง %d แต่เจอ %d", m.width, len(record)),
+		}}
+	}
+
+	values := make([]string, len(m.rules))
+	for i, idx := range m.indexes {
+		values[i] = record[idx]
+	}
+	return values, validateRecord(values, m.rules)
+}
+
+// normalizeColumn ทำให้ชื่อคอลัมน์เทียบกันได้โดยไม่สนตัวพิมพ์และเครื่องหมาย _ - ช่องว่าง
+func normalizeColumn(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer("_", "", "-", "", " ", "").Replace(name)
+}
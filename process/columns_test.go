@@ -0,0 +1,132 @@
+package process
+
+import (
+	"errors"
+	"mcmc/config"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveColumns(t *testing.T) {
+	rules := []ColumnRule{
+		{Name: "DocNo", Kind: KindText, Required: true},
+		{Name: "ItemId", Kind: KindText},
+		{Name: "Quantity", Kind: KindNumber},
+	}
+
+	tests := []struct {
+		name     string
+		header   []string
+		cfg      config.ColumnsConfig
+		want     []int    // ตำแหน่งในไฟล์ของแต่ละกฎ
+		problems []string // ข้อความที่ต้องพบใน error (nil = ต้องสำเร็จ)
+	}{
+		{name: "ลำดับตรงกัน", header: []string{"DocNo", "ItemId", "Quantity"}, want: []int{0, 1, 2}},
+		{name: "สลับลำดับ", header: []string{"Quantity", "DocNo", "ItemId"}, want: []int{1, 2, 0}},
+		{name: "ไม่สนตัวพิมพ์และเครื่องหมาย", header: []string{"doc_no", "ITEM-ID", " quantity "}, want: []int{0, 1, 2}},
+		{name: "ตัด BOM ของคอลัมน์แรก", header: []string{"\uFEFFDocNo", "ItemId", "Quantity"}, want: []int{0, 1, 2}},
+		{
+			name:   "ชื่อแทน",
+			header: []string{"DocumentNo", "ItemId", "Qty"},
+			cfg:    config.ColumnsConfig{Aliases: map[string]string{"document_no": "DocNo", "QTY": "quantity"}},
+			want:   []int{0, 1, 2},
+		},
+		{
+			name:   "อนุญาตคอลัมน์เกิน",
+			header: []string{"DocNo", "Extra", "ItemId", "Quantity"},
+			cfg:    config.ColumnsConfig{AllowExtra: true},
+			want:   []int{0, 2, 3},
+		},
+		{name: "ขาดคอลัมน์", header: []string{"DocNo"}, problems: []string{"ขาดคอลัมน์ ItemId, Quantity"}},
+		{name: "คอลัมน์ซ้ำ", header: []string{"DocNo", "ItemId", "Quantity", "doc_no"}, problems: []string{"คอลัมน์ซ้ำ doc_no"}},
+		{name: "คอลัมน์ที่ไม่รู้จัก", header: []string{"DocNo", "ItemId", "Quantity", "Extra"}, problems: []string{"คอลัมน์ที่ไม่รู้จัก Extra"}},
+		{
+			name:     "รายงานทุกปัญหาพร้อมกัน",
+			header:   []string{"DocNo", "DocNo", "Extra"},
+			problems: []string{"ขาดคอลัมน์ ItemId, Quantity", "คอลัมน์ซ้ำ DocNo", "คอลัมน์ที่ไม่รู้จัก Extra"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapping, err := resolveColumns(tt.header, rules, tt.cfg)
+			if tt.problems != nil {
+				if !errors.Is(err, ErrSchemaMismatch) {
+					t.Fatalf("resolveColumns(%q) error = %v ต้องการ ErrSchemaMismatch", tt.header, err)
+				}
+				for _, problem := range tt.problems {
+					if !strings.Contains(err.Error(), problem) {
+						t.Errorf("error %q ไม่มี %q", err, problem)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveColumns(%q): %v", tt.header, err)
+			}
+			if !reflect.DeepEqual(mapping.indexes, tt.want) {
+				t.Errorf("resolveColumns(%q) = %v ต้องการ %v", tt.header, mapping.indexes, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnMappingValidate(t *testing.T) {
+	rules := []ColumnRule{
+		{Name: "DocNo", Kind: KindText, Required: true},
+		{Name: "Quantity", Kind: KindNumber},
+	}
+	mapping, err := resolveColumns([]string{"Quantity", "Extra", "DocNo"}, rules, config.ColumnsConfig{AllowExtra: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		record     []string
+		want       []string
+		violations []string // คอลัมน์ที่ไม่ผ่าน
+	}{
+		{name: "เรียงตามกฎ", record: []string{"3", "x", "SO-1"}, want: []string{"SO-1", "3"}},
+		{name: "ค่าไม่ผ่าน", record: []string{"abc", "x", ""}, want: []string{"", "abc"}, violations: []string{"DocNo", "Quantity"}},
+		{name: "จำนวนฟิลด์ไม่ตรง", record: []string{"3", "SO-1"}, violations: []string{"*"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, violations := mapping.validate(tt.record)
+			if !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("validate(%q) = %q ต้องการ %q", tt.record, values, tt.want)
+			}
+			var columns []string
+			for _, v := range violations {
+				columns = append(columns, v.Column)
+			}
+			if !reflect.DeepEqual(columns, tt.violations) {
+				t.Errorf("validate(%q) ไม่ผ่านที่ %v ต้องการ %v", tt.record, columns, tt.violations)
+			}
+		})
+	}
+}
+
+func TestNormalizeColumn(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"DocNo", "docno"},
+		{"doc_no", "docno"},
+		{" Doc-No ", "docno"},
+		{"IO_Number", "ionumber"},
+		{"SO Customer Id", "socustomerid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeColumn(tt.name); got != tt.want {
+				t.Errorf("normalizeColumn(%q) = %q ต้องการ %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
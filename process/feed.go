@@ -0,0 +1,206 @@
+package process
+
+import (
+	"encoding/csv"
+	"fmt"
+	"mcmc/config"
+	"mcmc/database"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Feed คือ feed ที่กำหนดผ่าน schema พร้อมกฎการตรวจสอบและคอลัมน์ปลายทางที่เตรียมไว้แล้ว
+type Feed struct {
+	Schema  config.FeedSchema
+	rules   []ColumnRule
+	columns []database.Column // คอลัมน์ปลายทางตามลำดับของ rules ตามด้วย source_file และ created_at
+	keys    []string
+	comma   rune
+}
+
+// Registry เก็บ feed ทั้งหมดที่กำหนดผ่าน schema และหา feed ที่ตรงกับชื่อไฟล์
+type Registry struct {
+	feeds []*Feed
+}
+
+// NewRegistry สร้าง Registry จาก schema ที่ตรวจสอบแล้วใน config
+func NewRegistry(schemas []config.FeedSchema) *Registry {
+	registry := &Registry{}
+	for _, schema := range schemas {
+		registry.feeds = append(registry.feeds, newFeed(schema))
+	}
+	return registry
+}
+
+// Feeds คืน feed ทั้งหมดตามลำดับที่กำหนดใน config
+func (r *Registry) Feeds() []*Feed {
+	return r.feeds
+}
+
+// Match คืน feed แรกที่ชื่อไฟล์ตรงกับ pattern (nil = ไม่พบ)
+func (r *Registry) Match(fileName string) *Feed {
+	for _, feed := range r.feeds {
+		if feed.Schema.Match(fileName) {
+			return feed
+		}
+	}
+	return nil
+}
+
+func newFeed(schema config.FeedSchema) *Feed {
+	feed := &Feed{Schema: schema, comma: '|'}
+	if schema.Delimiter != "" {
+		feed.comma, _ = utf8.DecodeRuneInString(schema.Delimiter)
+	}
+
+	for _, column := range schema.Columns {
+		kind := column.Type
+		if kind == "" {
+			kind = KindText
+		}
+		feed.rules = append(feed.rules, ColumnRule{
+			Name:      column.Name,
+			Kind:      kind,
+			Required:  column.Required,
+			MaxLength: column.MaxLength,
+			Allowed:   column.Allowed,
+		})
+		feed.columns = append(feed.columns, database.Column{Name: column.TargetName(), Type: columnType(kind), Size: column.MaxLength})
+	}
+	feed.keys = naturalKeys(schema)
+	feed.columns = append(feed.columns,
+		database.Column{Name: "source_file", Type: database.TypeText, Size: 255},
+		database.Column{Name: "created_at", Type: database.TypeDateTime},
+	)
+
+	return feed
+}
+
+// naturalKeys คืน natural_key ของ schema โดยใช้ชื่อคอลัมน์ปลายทางตามที่กำหนดใน columns
+// เพราะ config ตรวจสอบ natural_key แบบไม่สนตัวพิมพ์เล็กใหญ่ แต่การบันทึกข้อมูลเทียบชื่อคอลัมน์ตรงตัว
+func naturalKeys(schema config.FeedSchema) []string {
+	targets := map[string]string{}
+	for _, column := range schema.Columns {
+		targets[strings.ToLower(column.TargetName())] = column.TargetName()
+	}
+
+	keys := make([]string, len(schema.NaturalKey))
+	for i, key := range schema.NaturalKey {
+		keys[i] = key
+		if target, ok := targets[strings.ToLower(key)]; ok {
+			keys[i] = target
+		}
+	}
+	return keys
+}
+
+// columnType คืนชนิดข้อมูลของคอลัมน์ปลายทางตามชนิดของคอลัมน์ในไฟล์
+func columnType(kind string) string {
+	switch kind {
+	case KindNumber:
+		return database.TypeDecimal
+	case KindInteger:
+		return database.TypeInteger
+	case KindDate:
+		return database.TypeDateTime
+	}
+	return database.TypeText
+}
+
+// EnsureTables สร้างตารางปลายทางของทุก feed ที่ยังไม่มี
+func (r *Registry) EnsureTables(db *database.DB) error {
+	for _, feed := range r.feeds {
+		if err := db.EnsureTable(feed.Schema.Table, feed.columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClearFile ลบแถวที่เคยบันทึกจากไฟล์ fileName ในตารางของ feed เพื่อประมวลผลซ้ำ
+func (f *Feed) ClearFile(db *database.DB, fileName string) (int, error) {
+	return db.DeleteTableRowsBySourceFile(f.Schema.Table, fileName)
+}
+
+// ProcessFeedFile ประมวลผลไฟล์ของ feed ที่กำหนดผ่าน schema และบันทึกลงตารางปลายทาง
+// ใช้การจับคู่คอลัมน์ การตรวจสอบรายแถว และประเภทข้อผิดพลาดเดียวกับ ProcessFile
+func ProcessFeedFile(db *database.DB, filePath string, feed *Feed, cfg *config.Config) (*Result, error) {
+	fileName := filepath.Base(filePath)
+	result := &Result{FileName: fileName, FileType: feed.Schema.Name}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return result, result.fail(StageRead, ErrRead, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = feed.comma
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return result, result.fail(StageRead, ErrRead, fmt.Errorf("ไม่สามารถอ่าน header ได้: %v", err))
+	}
+
+	columns, err := resolveColumns(header, feed.rules, cfg.Columns)
+	if err != nil {
+		return result, result.fail(StageParse, ErrSchemaMismatch, err)
+	}
+
+	rejects := newRejectWriter(filePath, cfg.Validation.RejectDir, header, reader.Comma)
+	defer rejects.close()
+	s := &stream{
+		reader:       reader,
+		columns:      columns,
+		rejects:      rejects,
+		batchSize:    cfg.Load.BatchSize,
+		failOnReject: cfg.Validation.RejectPolicy != RejectPolicySkip,
+	}
+
+	loader := db.NewTableLoader(feed.Schema.Table, feed.columns, feed.Schema.LoadMode, feed.keys)
+	createdAt := time.Now()
+	result.RowsRead, err = streamRecords(s, func(values []string) []interface{} {
+		return feed.parseRecord(values, fileName, createdAt)
+	}, loader.LoadRows)
+	result.Load = loader.Result()
+	if err != nil {
+		return result, result.fail(stageOf(err), ErrSchemaMismatch, err)
+	}
+
+	if err := result.handleRejects(rejects, cfg.Validation); err != nil {
+		return result, err
+	}
+
+	result.RowsWritten = result.Load.Inserted + result.Load.Updated
+	return result, nil
+}
+
+// parseRecord แปลงค่าของหนึ่งแถวตามชนิดของคอลัมน์ แล้วต่อท้ายด้วย source_file และ created_at
+// ค่าว่างจะถูกบันทึกเป็น NULL
+func (f *Feed) parseRecord(values []string, sourceFile string, createdAt time.Time) []interface{} {
+	row := make([]interface{}, 0, len(values)+2)
+	for i, value := range values {
+		row = append(row, convertValue(f.rules[i].Kind, value))
+	}
+	return append(row, sourceFile, createdAt)
+}
+
+// convertValue แปลงค่าที่ผ่านการตรวจสอบแล้วตามชนิดของคอลัมน์ (ค่าว่าง = nil)
+func convertValue(kind, value string) interface{} {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	switch kind {
+	case KindNumber:
+		return parseFloat(value)
+	case KindInteger:
+		return parseInt(value)
+	case KindDate:
+		return parseDate(value)
+	}
+	return value
+}
@@ -0,0 +1,35 @@
+package process
+
+import (
+	"mcmc/config"
+	"reflect"
+	"testing"
+)
+
+func TestNaturalKeys(t *testing.T) {
+	columns := []config.FeedColumn{
+		{Name: "DocNo"},                    // target doc_no
+		{Name: "ItemId", Target: "ItemID"}, // target ระบุเอง
+		{Name: "Amount", Target: "amount_th"},
+	}
+
+	tests := []struct {
+		name string
+		keys []string
+		want []string
+	}{
+		{name: "ตรงกับ target", keys: []string{"doc_no", "ItemID"}, want: []string{"doc_no", "ItemID"}},
+		{name: "ตัวพิมพ์ต่างจาก target", keys: []string{"DOC_NO", "itemid"}, want: []string{"doc_no", "ItemID"}},
+		{name: "target ที่กำหนดเอง", keys: []string{"AMOUNT_TH"}, want: []string{"amount_th"}},
+		{name: "ไม่มี key", keys: nil, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := naturalKeys(config.FeedSchema{NaturalKey: tt.keys, Columns: columns})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("naturalKeys(%v) = %v ต้องการ %v", tt.keys, got, tt.want)
+			}
+		})
+	}
+}
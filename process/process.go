@@ -3,7 +3,8 @@ package process
 import (
 	"encoding/csv"
 	"fmt"
-	"io"
+	"math"
+	"mcmc/config"
 	"mcmc/database"
 	"mcmc/models"
 	"os"
@@ -14,275 +15,276 @@ import (
 )
 
 // ProcessFile ประมวลผลไฟล์ CSV และเตรียมข้อมูลสำหรับบันทึกลงฐานข้อมูล
-func ProcessFile(db *database.DB, filePath string) (int, error) {
+// โดยตรวจสอบข้อมูลรายแถวตาม cfg.Validation และบันทึก header และ item ตามวิธีที่กำหนดใน cfg.Load
+// คืน Result เสมอ (แม้เกิดข้อผิดพลาด) และ error ที่ตรวจสอบประเภทได้ด้วย errors.Is
+// เช่น ErrUnknownFileType, ErrSchemaMismatch, ErrValidation, ErrPersist
+func ProcessFile(db *database.DB, filePath string, cfg *config.Config) (*Result, error) {
 	// ตรวจสอบประเภทไฟล์จากชื่อ
 	fileName := filepath.Base(filePath)
-	fileType := ""
+	result := &Result{FileName: fileName}
 
-	if strings.Contains(fileName, "saleorder_header_") {
-		fileType = "header"
-	} else if strings.Contains(fileName, "saleorder_item_") {
-		fileType = "item"
-	} else if strings.Contains(fileName, "saleorder_summary_") {
-		fileType = "summary"
-	} else {
-		return 0, fmt.Errorf("ไม่รู้จักประเภทไฟล์: %s", fileName)
+	fileType, err := FileType(fileName)
+	if err != nil {
+		return result, result.fail(StageDetect, ErrUnknownFileType, err)
 	}
+	result.FileType = fileType
 
 	// เปิดไฟล์ CSV
 	file, err := os.Open(filePath)
 	if err != nil {
-		return 0, fmt.Errorf("ไม่สามารถเปิดไฟล์ได้: %v", err)
+		return result, result.fail(StageRead, ErrRead, err)
 	}
 	defer file.Close()
 
 	// สร้าง CSV reader
 	reader := csv.NewReader(file)
-	reader.Comma = '|' // กำหนดตัวคั่นเป็น pipe (|)
+	reader.Comma = '|'          // กำหนดตัวคั่นเป็น pipe (|)
+	reader.FieldsPerRecord = -1 // ตรวจสอบจำนวนฟิลด์เองทีละแถวเพื่อแยกแถวที่ไม่ถูกต้องออก
 
 	// อ่าน header
 	header, err := reader.Read()
 	if err != nil {
-		return 0, fmt.Errorf("ไม่สามารถอ่าน header ได้: %v", err)
+		return result, result.fail(StageRead, ErrRead, fmt.Errorf("ไม่สามารถอ่าน header ได้: %v", err))
 	}
 
-	// ประมวลผลตามประเภทไฟล์
-	var recordCount int
-
+	var rules []ColumnRule
 	switch fileType {
 	case "header":
-		headers, err := readHeaderData(reader, header, fileName)
-		if err != nil {
-			return 0, err
-		}
-		recordCount, _ = db.SaveSaleOrderHeader(headers)
-
+		rules = headerRules(cfg.Validation)
 	case "item":
-		items, err := readItemData(reader, header, fileName)
-		if err != nil {
-			return 0, err
-		}
-		recordCount, _ = db.SaveSaleOrderItem(items)
-
+		rules = itemRules(cfg.Validation)
 	case "summary":
-		summaries, err := readSummaryData(reader, header, fileName)
-		if err != nil {
-			return 0, err
-		}
-		recordCount, _ = db.SaveSaleOrderSummary(summaries)
+		rules = summaryRules(cfg.Validation)
 	}
-
+	columns, err := resolveColumns(header, rules, cfg.Columns)
 	if err != nil {
-		return 0, fmt.Errorf("ไม่สามารถบันทึกข้อมูลได้: %v", err)
+		return result, result.fail(StageParse, ErrSchemaMismatch, err)
 	}
 
-	return recordCount, nil
-}
-
-// readHeaderData อ่านข้อมูลจากไฟล์ header
-func readHeaderData(reader *csv.Reader, headers []string, sourceFile string) ([]models.SaleOrderHeader, error) {
-	var result []models.SaleOrderHeader
-
-	// ตรวจสอบ header
-	expectedHeaders := []string{"DocNo", "OnDate", "DeliveryDate", "SOCustomerId", "CustomerName", "Status", "TerritoryCode", "TotalAmount", "TotalVat", "Remark"}
-	if len(headers) < len(expectedHeaders) {
-		return nil, fmt.Errorf("จำนวน header ไม่ถูกต้อง: คาดหวัง %d แต่เจอ %d", len(expectedHeaders), len(headers))
+	// อ่านและบันทึกทีละช่วงตาม cfg.Load.BatchSize ภายใน transaction ของผู้เรียก
+	rejects := newRejectWriter(filePath, cfg.Validation.RejectDir, header, reader.Comma)
+	defer rejects.close()
+	s := &stream{
+		reader:       reader,
+		columns:      columns,
+		rejects:      rejects,
+		batchSize:    cfg.Load.BatchSize,
+		failOnReject: cfg.Validation.RejectPolicy != RejectPolicySkip,
 	}
 
-	// อ่านแต่ละแถว
-	lineCount := 0
-	for {
-		lineCount++
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("เกิดข้อผิดพลาดในการอ่านไฟล์บรรทัดที่ %d: %v", lineCount, err)
-		}
-
-		// ตรวจสอบจำนวนฟิลด์
-		if len(record) < len(expectedHeaders) {
-			return nil, fmt.Errorf("จำนวนฟิลด์ไม่ถูกต้องที่บรรทัด %d: คาดหวัง %d แต่เจอ %d", lineCount, len(expectedHeaders), len(record))
-		}
+	// ประมวลผลตามประเภทไฟล์
+	switch fileType {
+	case "header":
+		loader := db.NewSaleOrderHeaderLoader(cfg.Load.HeaderMode, cfg.Load.HeaderBulkCopy)
+		result.RowsRead, err = streamRecords(s, func(values []string) models.SaleOrderHeader {
+			return parseHeaderRecord(values, fileName)
+		}, func(headers []models.SaleOrderHeader) error {
+			return loader.Load(&headers)
+		})
+		result.Load = loader.Result()
 
-		// แปลงข้อมูลและเพิ่มลงในผลลัพธ์
-		onDate := parseDate(record[1])
-		deliveryDate := parseDate(record[2])
-		
-		// ตรวจสอบค่าว่าง
-		var soCustomerId, customerName, status, territoryCode, remark string
-		if len(record) > 3 && record[3] != "" {
-			soCustomerId = record[3]
-		}
-		if len(record) > 4 && record[4] != "" {
-			customerName = record[4]
-		}
-		if len(record) > 5 && record[5] != "" {
-			status = record[5]
-		}
-		if len(record) > 6 && record[6] != "" {
-			territoryCode = record[6]
-		}
-		if len(record) > 9 && record[9] != "" {
-			remark = record[9]
-		}
+	case "item":
+		loader := db.NewSaleOrderItemLoader(cfg.Load.ItemMode, cfg.Load.ItemBulkCopy)
+		result.RowsRead, err = streamRecords(s, func(values []string) models.SaleOrderItem {
+			return parseItemRecord(values, fileName)
+		}, func(items []models.SaleOrderItem) error {
+			return loader.Load(&items)
+		})
+		result.Load = loader.Result()
 
-		header := models.SaleOrderHeader{
-			DocNo:         record[0],
-			SOCustomerID:  soCustomerId,
-			CustomerName:  customerName, 
-			Status:        status,
-			TerritoryCode: territoryCode,
-			TotalAmount:   parseFloat(record[7]),
-			TotalVat:      parseFloat(record[8]),
-			Remark:        remark,
-			SourceFile:    sourceFile,
-			CreatedAt:     time.Now(),
-		}
-		
-		// ตรวจสอบว่ามีค่าวันที่หรือไม่ก่อนกำหนดให้กับ pointer
-		if !onDate.IsZero() {
-			header.OnDate = &onDate
-		}
-		if !deliveryDate.IsZero() {
-			header.DeliveryDate = &deliveryDate
-		}
+	case "summary":
+		result.Load = database.LoadResult{Mode: database.LoadModeAppend}
+		result.RowsRead, err = streamRecords(s, func(values []string) models.SaleOrderSummary {
+			return parseSummaryRecord(values, fileName)
+		}, func(summaries []models.SaleOrderSummary) error {
+			recordCount, err := db.SaveSaleOrderSummary(summaries)
+			result.Load.Inserted += recordCount
+			return err
+		})
+	}
+	if err != nil {
+		return result, result.fail(stageOf(err), ErrSchemaMismatch, err)
+	}
 
-		result = append(result, header)
+	if err := result.handleRejects(rejects, cfg.Validation); err != nil {
+		return result, err
 	}
 
+	result.RowsWritten = result.Load.Inserted + result.Load.Updated
 	return result, nil
 }
 
-// readItemData อ่านข้อมูลจากไฟล์ item
-func readItemData(reader *csv.Reader, headers []string, sourceFile string) ([]models.SaleOrderItem, error) {
-	var result []models.SaleOrderItem
-
-	// ตรวจสอบ header
-	expectedHeaders := []string{"DocNo", "ItemId", "ProductCode", "SOProductId", "SKUUnitTypeId", "Quantity", "Price", "Amount", "Vat", "VatRate", "ItemType", "OrderRank", "RefItemId", "IO_Number"}
-	if len(headers) < len(expectedHeaders) {
-		return nil, fmt.Errorf("จำนวน header ไม่ถูกต้อง: คาดหวัง %d แต่เจอ %d", len(expectedHeaders), len(headers))
+// ClearFile ลบข้อมูลที่เคยบันทึกจากไฟล์ saleorder ชื่อ fileName (ตาม source_file) เพื่อประมวลผลซ้ำ
+// แถวที่ถูก upsert ทับโดยไฟล์ที่ใหม่กว่าจะไม่ถูกลบ เพราะ source_file เปลี่ยนเป็นไฟล์นั้นแล้ว
+func ClearFile(db *database.DB, fileName string) (int, error) {
+	fileType, err := FileType(fileName)
+	if err != nil {
+		return 0, err
 	}
 
-	// อ่านแต่ละแถว
-	lineCount := 0
-	for {
-		lineCount++
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("เกิดข้อผิดพลาดในการอ่านไฟล์บรรทัดที่ %d: %v", lineCount, err)
-		}
+	switch fileType {
+	case "header":
+		return db.DeleteBySourceFile(&models.SaleOrderHeader{}, fileName)
+	case "item":
+		return db.DeleteBySourceFile(&models.SaleOrderItem{}, fileName)
+	default:
+		return db.DeleteBySourceFile(&models.SaleOrderSummary{}, fileName)
+	}
+}
 
-		// ตรวจสอบจำนวนฟิลด์
-		if len(record) < len(expectedHeaders) {
-			return nil, fmt.Errorf("จำนวนฟิลด์ไม่ถูกต้องที่บรรทัด %d: คาดหวัง %d แต่เจอ %d", lineCount, len(expectedHeaders), len(record))
-		}
+// FileType คืนประเภทของไฟล์ (header, item, summary) จากชื่อไฟล์
+func FileType(fileName string) (string, error) {
+	if strings.Contains(fileName, "saleorder_header_") {
+		return "header", nil
+	} else if strings.Contains(fileName, "saleorder_item_") {
+		return "item", nil
+	} else if strings.Contains(fileName, "saleorder_summary_") {
+		return "summary", nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownFileType, fileName)
+}
 
-		// ตรวจสอบค่าว่าง
-		var itemId, productCode, soProductId, skuUnitTypeId, itemType, refItemId, ioNumber string
-		if len(record) > 1 && record[1] != "" {
-			itemId = record[1]
-		}
-		if len(record) > 2 && record[2] != "" {
-			productCode = record[2]
-		}
-		if len(record) > 3 && record[3] != "" {
-			soProductId = record[3]
-		}
-		if len(record) > 4 && record[4] != "" {
-			skuUnitTypeId = record[4]
-		}
-		if len(record) > 10 && record[10] != "" {
-			itemType = record[10]
-		}
-		if len(record) > 12 && record[12] != "" {
-			refItemId = record[12]
-		}
-		if len(record) > 13 && record[13] != "" {
-			ioNumber = record[13]
-		}
+// parseHeaderRecord แปลงข้อมูลหนึ่งแถวของไฟล์ header
+// record เรียงตามลำดับคอลัมน์มาตรฐานและผ่านการตรวจสอบแล้ว
+func parseHeaderRecord(record []string, sourceFile string) models.SaleOrderHeader {
+	// แปลงข้อมูล
+	onDate := parseDate(record[1])
+	deliveryDate := parseDate(record[2])
+
+	// ตรวจสอบค่าว่าง
+	var soCustomerId, customerName, status, territoryCode, remark string
+	if len(record) > 3 && record[3] != "" {
+		soCustomerId = record[3]
+	}
+	if len(record) > 4 && record[4] != "" {
+		customerName = record[4]
+	}
+	if len(record) > 5 && record[5] != "" {
+		status = record[5]
+	}
+	if len(record) > 6 && record[6] != "" {
+		territoryCode = record[6]
+	}
+	if len(record) > 9 && record[9] != "" {
+		remark = record[9]
+	}
 
-		// แปลงข้อมูลและเพิ่มลงในผลลัพธ์
-		item := models.SaleOrderItem{
-			DocNo:         record[0],
-			ItemID:        itemId,
-			ProductCode:   productCode,
-			SOProductID:   soProductId,
-			SKUUnitTypeID: skuUnitTypeId,
-			Quantity:      parseFloat(record[5]),
-			Price:         parseFloat(record[6]),
-			Amount:        parseFloat(record[7]),
-			Vat:           parseFloat(record[8]),
-			VatRate:       parseFloat(record[9]),
-			ItemType:      itemType,
-			OrderRank:     parseInt(record[11]),
-			RefItemID:     refItemId,
-			IONumber:      ioNumber,
-			SourceFile:    sourceFile,
-			CreatedAt:     time.Now(),
-		}
+	header := models.SaleOrderHeader{
+		DocNo:         record[0],
+		SOCustomerID:  soCustomerId,
+		CustomerName:  customerName,
+		Status:        status,
+		TerritoryCode: territoryCode,
+		TotalAmount:   parseFloat(record[7]),
+		TotalVat:      parseFloat(record[8]),
+		Remark:        remark,
+		SourceFile:    sourceFile,
+		CreatedAt:     time.Now(),
+	}
 
-		result = append(result, item)
+	// ตรวจสอบว่ามีค่าวันที่หรือไม่ก่อนกำหนดให้กับ pointer
+	if !onDate.IsZero() {
+		header.OnDate = &onDate
+	}
+	if !deliveryDate.IsZero() {
+		header.DeliveryDate = &deliveryDate
 	}
 
-	return result, nil
+	return header
 }
 
-// readSummaryData อ่านข้อมูลจากไฟล์ summary
-func readSummaryData(reader *csv.Reader, headers []string, sourceFile string) ([]models.SaleOrderSummary, error) {
-	var result []models.SaleOrderSummary
-
-	// ตรวจสอบ header
-	expectedHeaders := []string{"HeaderCount", "ItemCount"}
-	if len(headers) < len(expectedHeaders) {
-		return nil, fmt.Errorf("จำนวน header ไม่ถูกต้อง: คาดหวัง %d แต่เจอ %d", len(expectedHeaders), len(headers))
+// parseItemRecord แปลงข้อมูลหนึ่งแถวของไฟล์ item
+// record เรียงตามลำดับคอลัมน์มาตรฐานและผ่านการตรวจสอบแล้ว
+func parseItemRecord(record []string, sourceFile string) models.SaleOrderItem {
+	// ตรวจสอบค่าว่าง
+	var itemId, productCode, soProductId, skuUnitTypeId, itemType, refItemId, ioNumber string
+	if len(record) > 1 && record[1] != "" {
+		itemId = record[1]
+	}
+	if len(record) > 2 && record[2] != "" {
+		productCode = record[2]
+	}
+	if len(record) > 3 && record[3] != "" {
+		soProductId = record[3]
+	}
+	if len(record) > 4 && record[4] != "" {
+		skuUnitTypeId = record[4]
+	}
+	if len(record) > 10 && record[10] != "" {
+		itemType = record[10]
+	}
+	if len(record) > 12 && record[12] != "" {
+		refItemId = record[12]
+	}
+	if len(record) > 13 && record[13] != "" {
+		ioNumber = record[13]
 	}
 
-	// อ่านแต่ละแถว
-	lineCount := 0
-	for {
-		lineCount++
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("เกิดข้อผิดพลาดในการอ่านไฟล์บรรทัดที่ %d: %v", lineCount, err)
-		}
-
-		// ตรวจสอบจำนวนฟิลด์
-		if len(record) < len(expectedHeaders) {
-			return nil, fmt.Errorf("จำนวนฟิลด์ไม่ถูกต้องที่บรรทัด %d: คาดหวัง %d แต่เจอ %d", lineCount, len(expectedHeaders), len(record))
-		}
+	// แปลงข้อมูล
+	item := models.SaleOrderItem{
+		DocNo:         record[0],
+		ItemID:        itemId,
+		ProductCode:   productCode,
+		SOProductID:   soProductId,
+		SKUUnitTypeID: skuUnitTypeId,
+		Quantity:      parseFloat(record[5]),
+		Price:         parseFloat(record[6]),
+		Amount:        parseFloat(record[7]),
+		Vat:           parseFloat(record[8]),
+		VatRate:       parseFloat(record[9]),
+		ItemType:      itemType,
+		OrderRank:     parseInt(record[11]),
+		RefItemID:     refItemId,
+		IONumber:      ioNumber,
+		SourceFile:    sourceFile,
+		CreatedAt:     time.Now(),
+	}
 
-		// แปลงข้อมูลและเพิ่มลงในผลลัพธ์
-		summary := models.SaleOrderSummary{
-			HeaderCount: parseInt(record[0]),
-			ItemCount:   parseInt(record[1]),
-			SourceFile:  sourceFile,
-			CreatedAt:   time.Now(),
-		}
+	return item
+}
 
-		result = append(result, summary)
+// parseSummaryRecord แปลงข้อมูลหนึ่งแถวของไฟล์ summary
+// record เรียงตามลำดับคอลัมน์มาตรฐานและผ่านการตรวจสอบแล้ว
+func parseSummaryRecord(record []string, sourceFile string) models.SaleOrderSummary {
+	// แปลงข้อมูล
+	summary := models.SaleOrderSummary{
+		HeaderCount: parseInt(record[0]),
+		ItemCount:   parseInt(record[1]),
+		SourceFile:  sourceFile,
+		CreatedAt:   time.Now(),
 	}
 
-	return result, nil
+	return summary
 }
 
 // ฟังก์ชันช่วยสำหรับแปลงข้อมูล
+// ใช้หลังจากตรวจสอบแถวด้วย ColumnRule แล้ว ค่าที่ว่างจะได้ค่าศูนย์
 func parseDate(dateStr string) time.Time {
+	date, _ := parseDateStrict(dateStr)
+	return date
+}
+
+func parseFloat(numStr string) float64 {
+	num, _ := parseFloatStrict(numStr)
+	return num
+}
+
+func parseInt(numStr string) int {
+	num, _ := parseIntStrict(numStr)
+	return num
+}
+
+// parseDateStrict แปลงข้อความเป็นวันที่ตามรูปแบบที่รองรับ ค่าว่างได้ค่าศูนย์
+func parseDateStrict(dateStr string) (time.Time, error) {
+	dateStr = strings.TrimSpace(dateStr)
 	if dateStr == "" {
-		return time.Time{}
+		return time.Time{}, nil
 	}
 
 	// ลองรูปแบบต่างๆ ของวันที่
 	layouts := []string{
-		"2006-01-02 15:04:05", 
-        "2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05",
 		"2006-01-02",
 		"02/01/2006",
 		"2/1/2006",
@@ -292,49 +294,44 @@ func parseDate(dateStr string) time.Time {
 	for _, layout := range layouts {
 		date, err := time.Parse(layout, dateStr)
 		if err == nil {
-			return date
+			return date, nil
 		}
 	}
 
 	// ถ้าแปลงไม่สำเร็จในทุกรูปแบบ
-	return time.Time{}
+	return time.Time{}, fmt.Errorf("รูปแบบวันที่ไม่ถูกต้อง: %q", dateStr)
 }
 
-func parseFloat(numStr string) float64 {
-	if numStr == "" {
-		return 0
-	}
-
-	// ลบตัวอักษรที่ไม่เกี่ยวข้องกับตัวเลข (เช่น สัญลักษณ์สกุลเงิน)
+// parseFloatStrict แปลงข้อความเป็นตัวเลข (รองรับตัวคั่นหลักพันด้วยคอมมา) ค่าว่างได้ 0
+func parseFloatStrict(numStr string) (float64, error) {
+	// ลบตัวคั่นหลักพันและช่องว่าง
 	cleanStr := strings.ReplaceAll(numStr, ",", "")
 	cleanStr = strings.TrimSpace(cleanStr)
-
-	num, err := strconv.ParseFloat(cleanStr, 64)
-	if err != nil {
-		return 0
+	if cleanStr == "" {
+		return 0, nil
 	}
 
-	return num
+	return strconv.ParseFloat(cleanStr, 64)
 }
 
-func parseInt(numStr string) int {
-	if numStr == "" {
-		return 0
-	}
-
-	// ลบตัวอักษรที่ไม่เกี่ยวข้องกับตัวเลข
+// parseIntStrict แปลงข้อความเป็นจำนวนเต็ม ยอมรับรูปแบบทศนิยมที่ไม่มีเศษ เช่น "3.0" ค่าว่างได้ 0
+func parseIntStrict(numStr string) (int, error) {
+	// ลบตัวคั่นหลักพันและช่องว่าง
 	cleanStr := strings.ReplaceAll(numStr, ",", "")
 	cleanStr = strings.TrimSpace(cleanStr)
+	if cleanStr == "" {
+		return 0, nil
+	}
 
 	num, err := strconv.Atoi(cleanStr)
 	if err != nil {
 		// ลองแปลงเป็น float ก่อนแล้วค่อยแปลงเป็น int
 		floatNum, floatErr := strconv.ParseFloat(cleanStr, 64)
-		if floatErr != nil {
-			return 0
+		if floatErr != nil || floatNum != math.Trunc(floatNum) {
+			return 0, fmt.Errorf("ไม่ใช่จำนวนเต็ม: %q", numStr)
 		}
-		return int(floatNum)
+		return int(floatNum), nil
 	}
 
-	return num
-}
\ No newline at end of file
+	return num, nil
+}
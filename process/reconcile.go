@@ -0,0 +1,71 @@
+package process
+
+import (
+	"fmt"
+	"mcmc/database"
+	"mcmc/models"
+	"time"
+)
+
+// สถานะผลการตรวจสอบจำนวนรายการ
+const (
+	ReconciliationMatched    = "matched"
+	ReconciliationMismatched = "mismatched"
+)
+
+// Reconcile เทียบจำนวน header และ item ใน summary กับจำนวนแถวที่บันทึกจากไฟล์ในชุดเดียวกัน (results)
+// นับจากแถวที่ผ่านการตรวจสอบในไฟล์แทนแถวในตาราง เพราะโหมด upsert รวมแถวที่ key ซ้ำกันเป็นแถวเดียว
+// แถวที่ถูกปฏิเสธจากการตรวจสอบนับรวมเป็นแถวที่ได้รับจาก vendor
+// ควรเรียกภายใน transaction เดียวกับที่บันทึกข้อมูล เพื่อให้อ่าน summary ที่ยังไม่ commit ได้
+// คืน error เฉพาะเมื่อไม่สามารถตรวจสอบได้ ส่วนผลที่ไม่ตรงกันจะอยู่ใน Status
+func Reconcile(db *database.DB, batchID string, results []*Result) (*models.BatchReconciliation, error) {
+	result := &models.BatchReconciliation{
+		BatchID:   batchID,
+		CreatedAt: time.Now(),
+	}
+
+	summaryFound := false
+	for _, r := range results {
+		fileType, err := FileType(r.FileName)
+		if err != nil {
+			return nil, err
+		}
+
+		switch fileType {
+		case "header":
+			result.ActualHeaderCount += r.RowsRead - r.RowsRejected
+			result.RejectedHeaderCount += r.RowsRejected
+
+		case "item":
+			result.ActualItemCount += r.RowsRead - r.RowsRejected
+			result.RejectedItemCount += r.RowsRejected
+
+		case "summary":
+			summaries, err := db.GetSaleOrderSummaries(r.FileName)
+			if err != nil {
+				return nil, err
+			}
+			for _, summary := range summaries {
+				result.ExpectedHeaderCount += summary.HeaderCount
+				result.ExpectedItemCount += summary.ItemCount
+				summaryFound = true
+			}
+		}
+	}
+
+	switch {
+	case !summaryFound:
+		result.Status = ReconciliationMismatched
+		result.Message = "ไม่พบข้อมูล summary ในชุดไฟล์"
+	case result.ExpectedHeaderCount != result.ActualHeaderCount+result.RejectedHeaderCount ||
+		result.ExpectedItemCount != result.ActualItemCount+result.RejectedItemCount:
+		result.Status = ReconciliationMismatched
+		result.Message = fmt.Sprintf("จำนวนรายการไม่ตรงกับ summary: header คาดหวัง %d แต่อ่านได้ %d (ปฏิเสธ %d), item คาดหวัง %d แต่อ่านได้ %d (ปฏิเสธ %d)",
+			result.ExpectedHeaderCount, result.ActualHeaderCount, result.RejectedHeaderCount,
+			result.ExpectedItemCount, result.ActualItemCount, result.RejectedItemCount)
+	default:
+		result.Status = ReconciliationMatched
+	}
+
+	return result, nil
+}
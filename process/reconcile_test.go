@@ -0,0 +1,155 @@
+package process
+
+import (
+	"mcmc/config"
+	"mcmc/database"
+	"mcmc/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/logger"
+)
+
+// ชื่อไฟล์ของชุดที่ใช้ทดสอบ
+const (
+	testHeaderFile  = "saleorder_header_20250514_MCMC-1747195635913-00001.csv"
+	testItemFile    = "saleorder_item_20250514_MCMC-1747195635913-00001.csv"
+	testSummaryFile = "saleorder_summary_20250514_MCMC-1747195635913-00001.csv"
+)
+
+// openTestDB สร้างฐานข้อมูล SQLite ใหม่ที่ทำ migration แล้วในโฟลเดอร์ชั่วคราวของ test
+func openTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.NewDB(config.DatabaseConfig{Driver: database.DriverSQLite, DBName: filepath.Join(t.TempDir(), "mcmc.db")})
+	if err != nil {
+		t.Fatalf("ไม่สามารถสร้างฐานข้อมูล SQLite ได้: %v", err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.MigrateUp(0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	return db
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     Result
+		item       Result
+		summary    []models.SaleOrderSummary
+		wantStatus string
+		wantMsg    string
+	}{
+		{
+			name:       "ตรงกับ summary",
+			header:     Result{RowsRead: 2},
+			item:       Result{RowsRead: 3},
+			summary:    []models.SaleOrderSummary{{HeaderCount: 2, ItemCount: 3}},
+			wantStatus: ReconciliationMatched,
+		},
+		{
+			name:       "นับแถวที่ถูกปฏิเสธรวมด้วย",
+			header:     Result{RowsRead: 2, RowsRejected: 1},
+			item:       Result{RowsRead: 4, RowsRejected: 1},
+			summary:    []models.SaleOrderSummary{{HeaderCount: 2, ItemCount: 4}},
+			wantStatus: ReconciliationMatched,
+		},
+		{
+			name:       "summary หลายแถวรวมกัน",
+			header:     Result{RowsRead: 2},
+			item:       Result{RowsRead: 3},
+			summary:    []models.SaleOrderSummary{{HeaderCount: 1, ItemCount: 1}, {HeaderCount: 1, ItemCount: 2}},
+			wantStatus: ReconciliationMatched,
+		},
+		{
+			name:       "item ไม่ตรง",
+			header:     Result{RowsRead: 2},
+			item:       Result{RowsRead: 2},
+			summary:    []models.SaleOrderSummary{{HeaderCount: 2, ItemCount: 3}},
+			wantStatus: ReconciliationMismatched,
+			wantMsg:    "item คาดหวัง 3 แต่อ่านได้ 2",
+		},
+		{
+			name:       "ไม่มี summary",
+			header:     Result{RowsRead: 2},
+			item:       Result{RowsRead: 3},
+			wantStatus: ReconciliationMismatched,
+			wantMsg:    "ไม่พบข้อมูล summary",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			for _, s := range tt.summary {
+				row := &models.SaleOrderSummary{HeaderCount: s.HeaderCount, ItemCount: s.ItemCount, SourceFile: testSummaryFile, CreatedAt: time.Now()}
+				if err := db.Create(row).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			tt.header.FileName = testHeaderFile
+			tt.item.FileName = testItemFile
+			results := []*Result{&tt.header, &tt.item, {FileName: testSummaryFile, RowsRead: len(tt.summary)}}
+
+			rec, err := Reconcile(db, "batch", results)
+			if err != nil {
+				t.Fatalf("Reconcile: %v", err)
+			}
+			if rec.Status != tt.wantStatus || !strings.Contains(rec.Message, tt.wantMsg) {
+				t.Errorf("Reconcile = %s %q ต้องการ %s %q", rec.Status, rec.Message, tt.wantStatus, tt.wantMsg)
+			}
+			wantHeaders, wantItems := tt.header.RowsRead-tt.header.RowsRejected, tt.item.RowsRead-tt.item.RowsRejected
+			if rec.BatchID != "batch" || rec.ActualHeaderCount != wantHeaders || rec.ActualItemCount != wantItems {
+				t.Errorf("Reconcile = %+v ต้องการ header %d item %d", rec, wantHeaders, wantItems)
+			}
+		})
+	}
+
+	if _, err := Reconcile(openTestDB(t), "batch", []*Result{{FileName: "customer_master_20250514.csv"}}); err == nil {
+		t.Errorf("Reconcile ต้องคืน error เมื่อไม่รู้จักประเภทไฟล์")
+	}
+}
+
+// TestReconcileUpsertDuplicates ตรวจว่าแถวที่ DocNo ซ้ำกันในโหมด upsert ซึ่งรวมเป็นแถวเดียวในตาราง
+// ยังนับครบตาม summary
+func TestReconcileUpsertDuplicates(t *testing.T) {
+	db := openTestDB(t)
+	cfg := config.Default()
+	cfg.Load.HeaderMode = database.LoadModeUpsert
+
+	dir := t.TempDir()
+	files := map[string]string{
+		testHeaderFile:  "DocNo|OnDate|DeliveryDate|SOCustomerId|CustomerName|Status|TerritoryCode|TotalAmount|TotalVat|Remark\nSO-1|||||||100||\nSO-1|||||||120||\n",
+		testItemFile:    "DocNo|ItemId|ProductCode|SOProductId|SKUUnitTypeId|Quantity|Price|Amount|Vat|VatRate|ItemType|OrderRank|RefItemId|IO_Number\nSO-1|1||||1|120|120||||||\n",
+		testSummaryFile: "HeaderCount|ItemCount\n2|1\n",
+	}
+	var results []*Result
+	for _, name := range []string{testHeaderFile, testItemFile, testSummaryFile} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		result, err := ProcessFile(db, path, cfg)
+		if err != nil {
+			t.Fatalf("ProcessFile(%s): %v", name, err)
+		}
+		results = append(results, result)
+	}
+
+	var stored int64
+	if err := db.Model(&models.SaleOrderHeader{}).Count(&stored).Error; err != nil || stored != 1 {
+		t.Fatalf("บันทึก header %d แถว (%v) ต้องการ 1 แถวหลัง upsert", stored, err)
+	}
+
+	rec, err := Reconcile(db, "batch", results)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if rec.Status != ReconciliationMatched || rec.ActualHeaderCount != 2 {
+		t.Errorf("Reconcile = %s %q (header %d) ต้องการ matched และ header 2", rec.Status, rec.Message, rec.ActualHeaderCount)
+	}
+}
@@ -0,0 +1,107 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"mcmc/config"
+	"mcmc/database"
+)
+
+// ประเภทข้อผิดพลาดที่ผู้เรียกใช้ตรวจสอบได้ด้วย errors.Is
+var (
+	ErrUnknownFileType = errors.New("ไม่รู้จักประเภทไฟล์")
+	ErrRead            = errors.New("ไม่สามารถอ่านไฟล์ได้")
+	ErrSchemaMismatch  = errors.New("โครงสร้างไฟล์ไม่ตรงกับที่คาดหวัง")
+	ErrValidation      = errors.New("ข้อมูลไม่ผ่านการตรวจสอบ")
+	ErrPersist         = errors.New("ไม่สามารถบันทึกข้อมูลได้")
+	ErrReconciliation  = errors.New("จำนวนรายการไม่ตรงกับ summary")
+)
+
+// IsKnown ตรวจสอบว่า err ระบุประเภทข้อผิดพลาดของการประมวลผลไว้แล้วหรือไม่
+func IsKnown(err error) bool {
+	for _, kind := range []error{ErrUnknownFileType, ErrRead, ErrSchemaMismatch, ErrValidation, ErrPersist, ErrReconciliation} {
+		if errors.Is(err, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// ขั้นตอนของการประมวลผลไฟล์
+const (
+	StageDetect   = "detect"
+	StageRead     = "read"
+	StageParse    = "parse"
+	StageValidate = "validate"
+	StagePersist  = "persist"
+)
+
+// StageError คือข้อผิดพลาดที่เกิดขึ้นในขั้นตอนหนึ่งของการประมวลผลไฟล์
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("[%s] %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// Result สรุปผลการประมวลผลไฟล์หนึ่งไฟล์
+type Result struct {
+	FileName     string
+	FileType     string
+	RowsRead     int
+	RowsWritten  int
+	RowsRejected int
+	RejectFile   string // path ของไฟล์ reject (ถ้ามีแถวที่ไม่ผ่านการตรวจสอบ)
+	Load         database.LoadResult
+	Errors       []*StageError
+}
+
+// Stats คืนจำนวนแถวสำหรับบันทึกลงประวัติการประมวลผล (r เป็น nil ได้)
+func (r *Result) Stats() database.AttemptStats {
+	if r == nil {
+		return database.AttemptStats{}
+	}
+	return database.AttemptStats{
+		RowsRead:     r.RowsRead,
+		RowsWritten:  r.RowsWritten,
+		RowsRejected: r.RowsRejected,
+		Load:         r.Load,
+	}
+}
+
+// fail บันทึกข้อผิดพลาดของขั้นตอนและคืน error ที่ห่อด้วยประเภทข้อผิดพลาด
+// ถ้า err ระบุประเภทไว้แล้ว (เช่น ErrRead จากการอ่านบรรทัด) จะคงประเภทเดิมไว้
+func (r *Result) fail(stage string, kind error, err error) error {
+	wrapped := err
+	if !IsKnown(err) {
+		wrapped = fmt.Errorf("%w: %v", kind, err)
+	}
+	stageErr := &StageError{Stage: stage, Err: wrapped}
+	r.Errors = append(r.Errors, stageErr)
+	return stageErr
+}
+
+// handleRejects ปิดไฟล์ reject ที่เขียนระหว่างอ่านไฟล์
+// และคืน ErrValidation ถ้ามีแถวที่ไม่ผ่านการตรวจสอบและนโยบายกำหนดให้ทั้งไฟล์ล้มเหลว
+func (r *Result) handleRejects(rejects *rejectWriter, cfg config.ValidationConfig) error {
+	r.RowsRejected = rejects.count
+	if err := rejects.close(); err != nil {
+		return r.fail(StageValidate, ErrValidation, err)
+	}
+	if rejects.count == 0 {
+		return nil
+	}
+
+	r.RejectFile = rejects.path
+	if cfg.RejectPolicy != RejectPolicySkip {
+		return r.fail(StageValidate, ErrValidation,
+			fmt.Errorf("พบ %d แถวที่ไม่ผ่านการตรวจสอบ (ดูรายละเอียดที่ %s)", rejects.count, r.RejectFile))
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package process
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// stream อ่านแถวจากไฟล์ CSV ทีละแถวและส่งต่อเป็นช่วง ทำให้ใช้หน่วยความจำคงที่ไม่ว่าไฟล์จะใหญ่เท่าใด
+type stream struct {
+	reader       *csv.Reader
+	columns      *columnMapping
+	rejects      *rejectWriter
+	batchSize    int
+	failOnReject bool // true = หยุดบันทึกเมื่อพบแถวที่ไม่ผ่าน (ทั้งไฟล์จะล้มเหลวอยู่แล้ว) แต่ยังอ่านต่อเพื่อรวบรวม reject
+}
+
+// streamRecords อ่านทุกแถวจาก s ตรวจสอบและแปลงด้วย parse แล้วส่งให้ flush ทีละไม่เกิน batchSize แถว
+// แถวที่ไม่ผ่านการตรวจสอบจะถูกเขียนลงไฟล์ reject ทันที คืนจำนวนแถวที่อ่านได้ทั้งหมด (รวมแถวที่ไม่ผ่าน)
+// error จาก flush จะถูกห่อด้วย ErrPersist และ error จากการเขียน reject ห่อด้วย ErrValidation
+func streamRecords[T any](s *stream, parse func(values []string) T, flush func(batch []T) error) (int, error) {
+	batch := make([]T, 0, s.batchSize)
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if s.failOnReject && s.rejects.count > 0 {
+			batch = batch[:0]
+			return nil
+		}
+		if err := flush(batch); err != nil {
+			return fmt.Errorf("%w: %v", ErrPersist, err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	rows := 0
+	lineCount := 0
+	for {
+		lineCount++
+		record, err := s.reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rows, fmt.Errorf("%w: เกิดข้อผิดพลาดในการอ่านไฟล์บรรทัดที่ %d: %v", ErrRead, lineCount, err)
+		}
+		rows++
+
+		// ตรวจสอบจำนวนฟิลด์และค่าของแต่ละคอลัมน์ แถวที่ไม่ผ่านจะถูกแยกออกพร้อมเหตุผล
+		values, violations := s.columns.validate(record)
+		if len(violations) > 0 {
+			if err := s.rejects.add(Rejection{Line: lineCount + 1, Record: record, Violations: violations}); err != nil {
+				return rows, fmt.Errorf("%w: %v", ErrValidation, err)
+			}
+			continue
+		}
+
+		// values จัดเรียงตามลำดับคอลัมน์มาตรฐานแล้ว
+		batch = append(batch, parse(values))
+		if len(batch) >= s.batchSize {
+			if err := send(); err != nil {
+				return rows, err
+			}
+		}
+	}
+
+	return rows, send()
+}
+
+// stageOf คืนขั้นตอนที่ทำให้เกิด err จากการอ่านไฟล์แบบ stream
+func stageOf(err error) string {
+	switch {
+	case errors.Is(err, ErrPersist):
+		return StagePersist
+	case errors.Is(err, ErrValidation):
+		return StageValidate
+	case errors.Is(err, ErrRead):
+		return StageRead
+	}
+	return StageParse
+}
+
+// rejectWriter เขียนแถวที่ไม่ผ่านการตรวจสอบพร้อมเหตุผลลงไฟล์ทันทีที่พบ
+// โดยใช้ตัวคั่นเดียวกับไฟล์ต้นทาง และสร้างไฟล์เมื่อพบแถวแรกเท่านั้น
+type rejectWriter struct {
+	path   string
+	header []string
+	comma  rune
+	file   *os.File
+	writer *csv.Writer
+	count  int
+}
+
+func newRejectWriter(filePath, rejectDir string, header []string, comma rune) *rejectWriter {
+	return &rejectWriter{path: rejectFilePath(filePath, rejectDir), header: header, comma: comma}
+}
+
+// add เขียนแถวที่ไม่ผ่านการตรวจสอบหนึ่งแถว
+func (w *rejectWriter) add(reject Rejection) error {
+	if w.writer == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+
+	reasons := make([]string, len(reject.Violations))
+	for i, v := range reject.Violations {
+		reasons[i] = v.String()
+	}
+	row := append([]string{strconv.Itoa(reject.Line)}, reject.Record...)
+	if err := w.writer.Write(append(row, strings.Join(reasons, "; "))); err != nil {
+		return fmt.Errorf("ไม่สามารถเขียนไฟล์ reject ได้: %v", err)
+	}
+	w.count++
+	return nil
+}
+
+func (w *rejectWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return fmt.Errorf("ไม่สามารถสร้างโฟลเดอร์สำหรับไฟล์ reject ได้: %v", err)
+	}
+
+	file, err := os.Create(w.path)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถสร้างไฟล์ reject ได้: %v", err)
+	}
+	w.file = file
+	w.writer = csv.NewWriter(file)
+	w.writer.Comma = w.comma
+
+	row := append([]string{"LineNumber"}, w.header...)
+	if err := w.writer.Write(append(row, "RejectReasons")); err != nil {
+		return fmt.Errorf("ไม่สามารถเขียนไฟล์ reject ได้: %v", err)
+	}
+	return nil
+}
+
+// close บันทึกข้อมูลที่ค้างอยู่และปิดไฟล์ (เรียกซ้ำได้)
+func (w *rejectWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	w.writer.Flush()
+	err := w.writer.Error()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถเขียนไฟล์ reject ได้: %v", err)
+	}
+	return nil
+}
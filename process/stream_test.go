@@ -0,0 +1,209 @@
+package process
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"mcmc/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestStream สร้าง stream ของข้อมูล CSV (คั่นด้วย |) ที่มีคอลัมน์ DocNo และ Quantity
+func newTestStream(t *testing.T, data string, batchSize int, failOnReject bool) *stream {
+	t.Helper()
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.Comma = '|'
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []ColumnRule{
+		{Name: "DocNo", Kind: KindText, Required: true},
+		{Name: "Quantity", Kind: KindNumber},
+	}
+	columns, err := resolveColumns(header, rules, config.ColumnsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejects := newRejectWriter(filepath.Join(t.TempDir(), "test.csv"), "", header, reader.Comma)
+	t.Cleanup(func() { rejects.close() })
+
+	return &stream{reader: reader, columns: columns, rejects: rejects, batchSize: batchSize, failOnReject: failOnReject}
+}
+
+func TestStreamRecords(t *testing.T) {
+	const header = "DocNo|Quantity\n"
+	errFlush := errors.New("flush ล้มเหลว")
+
+	tests := []struct {
+		name         string
+		data         string
+		batchSize    int
+		failOnReject bool
+		flushErr     error
+		wantRows     int
+		wantBatches  [][]string
+		wantRejects  int
+		wantErr      error
+	}{
+		{
+			name:        "แบ่งเป็นช่วงตาม batchSize",
+			data:        header + "A|1\nB|2\nC|3\nD|4\nE|5\n",
+			batchSize:   2,
+			wantRows:    5,
+			wantBatches: [][]string{{"A", "B"}, {"C", "D"}, {"E"}},
+		},
+		{
+			name:        "ไม่มีแถวข้อมูล",
+			data:        header,
+			batchSize:   2,
+			wantRows:    0,
+			wantBatches: nil,
+		},
+		{
+			name:        "ข้ามแถวที่ไม่ผ่านเมื่อนโยบายเป็น skip",
+			data:        header + "A|1\n|2\nC|x\nD|4\n",
+			batchSize:   10,
+			wantRows:    4,
+			wantBatches: [][]string{{"A", "D"}},
+			wantRejects: 2,
+		},
+		{
+			name:         "หยุดบันทึกหลังพบแถวที่ไม่ผ่านเมื่อนโยบายเป็น fail",
+			data:         header + "A|1\nB|2\nC|x\nD|4\nE|5\n",
+			batchSize:    2,
+			failOnReject: true,
+			wantRows:     5,
+			wantBatches:  [][]string{{"A", "B"}},
+			wantRejects:  1,
+		},
+		{
+			name:        "จำนวนฟิลด์ไม่ตรงกับ header",
+			data:        header + "A|1|extra\nB|2\n",
+			batchSize:   10,
+			wantRows:    2,
+			wantBatches: [][]string{{"B"}},
+			wantRejects: 1,
+		},
+		{
+			name:      "flush ล้มเหลว",
+			data:      header + "A|1\nB|2\n",
+			batchSize: 1,
+			flushErr:  errFlush,
+			wantRows:  1,
+			wantErr:   ErrPersist,
+		},
+		{
+			name:        "อ่านไฟล์ไม่ได้",
+			data:        header + "A|1\nB|\"2\n",
+			batchSize:   10,
+			wantRows:    1,
+			wantBatches: nil,
+			wantErr:     ErrRead,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStream(t, tt.data, tt.batchSize, tt.failOnReject)
+
+			var batches [][]string
+			rows, err := streamRecords(s, func(values []string) string { return values[0] }, func(batch []string) error {
+				if tt.flushErr != nil {
+					return tt.flushErr
+				}
+				batches = append(batches, append([]string(nil), batch...))
+				return nil
+			})
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("streamRecords error = %v ต้องการ %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("streamRecords: %v", err)
+			}
+			if rows != tt.wantRows {
+				t.Errorf("streamRecords อ่านได้ %d แถว ต้องการ %d", rows, tt.wantRows)
+			}
+			if !reflect.DeepEqual(batches, tt.wantBatches) {
+				t.Errorf("ส่งให้ flush เป็น %v ต้องการ %v", batches, tt.wantBatches)
+			}
+			if s.rejects.count != tt.wantRejects {
+				t.Errorf("reject %d แถว ต้องการ %d", s.rejects.count, tt.wantRejects)
+			}
+		})
+	}
+}
+
+func TestStageOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w: x", ErrPersist), StagePersist},
+		{fmt.Errorf("%w: x", ErrValidation), StageValidate},
+		{fmt.Errorf("%w: x", ErrRead), StageRead},
+		{errors.New("อื่น ๆ"), StageParse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := stageOf(tt.err); got != tt.want {
+				t.Errorf("stageOf(%v) = %q ต้องการ %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRejectWriter(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "in", "saleorder_item.csv")
+	rejectDir := filepath.Join(dir, "rejects")
+
+	// ไม่มีแถวที่ไม่ผ่านต้องไม่สร้างไฟล์
+	empty := newRejectWriter(filePath, rejectDir, []string{"DocNo", "Quantity"}, '|')
+	if err := empty.close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(empty.path); !os.IsNotExist(err) {
+		t.Errorf("สร้างไฟล์ reject %s ทั้งที่ไม่มีแถวที่ไม่ผ่าน", empty.path)
+	}
+
+	w := newRejectWriter(filePath, rejectDir, []string{"DocNo", "Quantity"}, '|')
+	rejects := []Rejection{
+		{Line: 3, Record: []string{"", "2"}, Violations: []Violation{{Column: "DocNo", Message: "ต้องระบุค่า"}}},
+		{Line: 5, Record: []string{"C", "x"}, Violations: []Violation{{Column: "Quantity", Message: "ต้องเป็นตัวเลข"}, {Column: "*", Message: "อื่น ๆ"}}},
+	}
+	for _, r := range rejects {
+		if err := w.add(r); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	if err := w.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := w.close(); err != nil {
+		t.Errorf("close ครั้งที่สอง: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(rejectDir, "saleorder_item.csv.rejects.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "LineNumber|DocNo|Quantity|RejectReasons\n" +
+		"3||2|DocNo: ต้องระบุค่า\n" +
+		"5|C|x|Quantity: ต้องเป็นตัวเลข; *: อื่น ๆ\n"
+	if string(data) != want {
+		t.Errorf("ไฟล์ reject =\n%s\nต้องการ\n%s", data, want)
+	}
+	if w.count != len(rejects) {
+		t.Errorf("count = %d ต้องการ %d", w.count, len(rejects))
+	}
+}
@@ -0,0 +1,156 @@
+package process
+
+import (
+	"fmt"
+	"mcmc/config"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ชนิดข้อมูลของคอลัมน์ที่ใช้ตรวจสอบ
+const (
+	KindText    = "text"
+	KindNumber  = "number"
+	KindInteger = "integer"
+	KindDate    = "date"
+)
+
+// นโยบายเมื่อพบแถวที่ไม่ผ่านการตรวจสอบ
+const (
+	RejectPolicyFail = "fail" // ถือว่าทั้งไฟล์ล้มเหลว
+	RejectPolicySkip = "skip" // บันทึกเฉพาะแถวที่ผ่านการตรวจสอบ
+)
+
+// ColumnRule กฎการตรวจสอบค่าของคอลัมน์หนึ่ง
+type ColumnRule struct {
+	Name      string
+	Kind      string
+	Required  bool
+	MaxLength int      // 0 = ไม่จำกัด (ตรงกับ size ของคอลัมน์ใน models)
+	Allowed   []string // ว่าง = ไม่จำกัดค่า
+}
+
+// Violation คือปัญหาที่พบในคอลัมน์หนึ่งของแถว
+type Violation struct {
+	Column  string
+	Message string
+}
+
+func (v Violation) String() string {
+	return v.Column + ": " + v.Message
+}
+
+// Rejection คือแถวที่ไม่ผ่านการตรวจสอบพร้อมเหตุผล
+type Rejection struct {
+	Line       int // เลขบรรทัดในไฟล์ (บรรทัดแรกคือ header)
+	Record     []string
+	Violations []Violation
+}
+
+// headerRules กฎการตรวจสอบของไฟล์ header เรียงตามลำดับคอลัมน์
+func headerRules(cfg config.ValidationConfig) []ColumnRule {
+	return []ColumnRule{
+		{Name: "DocNo", Kind: KindText, Required: true, MaxLength: 50},
+		{Name: "OnDate", Kind: KindDate},
+		{Name: "DeliveryDate", Kind: KindDate},
+		{Name: "SOCustomerId", Kind: KindText, MaxLength: 50},
+		{Name: "CustomerName", Kind: KindText, MaxLength: 255},
+		{Name: "Status", Kind: KindText, MaxLength: 50, Allowed: cfg.AllowedStatuses},
+		{Name: "TerritoryCode", Kind: KindText, MaxLength: 50},
+		{Name: "TotalAmount", Kind: KindNumber},
+		{Name: "TotalVat", Kind: KindNumber},
+		{Name: "Remark", Kind: KindText},
+	}
+}
+
+// itemRules กฎการตรวจสอบของไฟล์ item เรียงตามลำดับคอลัมน์
+func itemRules(cfg config.ValidationConfig) []ColumnRule {
+	return []ColumnRule{
+		{Name: "DocNo", Kind: KindText, Required: true, MaxLength: 50},
+		{Name: "ItemId", Kind: KindText, MaxLength: 50},
+		{Name: "ProductCode", Kind: KindText, MaxLength: 50},
+		{Name: "SOProductId", Kind: KindText, MaxLength: 50},
+		{Name: "SKUUnitTypeId", Kind: KindText, MaxLength: 50},
+		{Name: "Quantity", Kind: KindNumber},
+		{Name: "Price", Kind: KindNumber},
+		{Name: "Amount", Kind: KindNumber},
+		{Name: "Vat", Kind: KindNumber},
+		{Name: "VatRate", Kind: KindNumber},
+		{Name: "ItemType", Kind: KindText, MaxLength: 50},
+		{Name: "OrderRank", Kind: KindInteger},
+		{Name: "RefItemId", Kind: KindText, MaxLength: 50},
+		{Name: "IO_Number", Kind: KindText, MaxLength: 50},
+	}
+}
+
+// summaryRules กฎการตรวจสอบของไฟล์ summary เรียงตามลำดับคอลัมน์
+func summaryRules(cfg config.ValidationConfig) []ColumnRule {
+	return []ColumnRule{
+		{Name: "HeaderCount", Kind: KindInteger, Required: true},
+		{Name: "ItemCount", Kind: KindInteger, Required: true},
+	}
+}
+
+// Check ตรวจสอบค่าตามกฎและคืนข้อความปัญหาที่พบ (ว่าง = ผ่าน)
+func (r ColumnRule) Check(value string) string {
+	if strings.TrimSpace(value) == "" {
+		if r.Required {
+			return "ต้องระบุค่า"
+		}
+		return ""
+	}
+
+	switch r.Kind {
+	case KindNumber:
+		if _, err := parseFloatStrict(value); err != nil {
+			return fmt.Sprintf("ต้องเป็นตัวเลข (%q)", value)
+		}
+	case KindInteger:
+		if _, err := parseIntStrict(value); err != nil {
+			return fmt.Sprintf("ต้องเป็นจำนวนเต็ม (%q)", value)
+		}
+	case KindDate:
+		if _, err := parseDateStrict(value); err != nil {
+			return fmt.Sprintf("รูปแบบวันที่ไม่ถูกต้อง (%q)", value)
+		}
+	}
+
+	if r.MaxLength > 0 && utf8.RuneCountInString(value) > r.MaxLength {
+		return fmt.Sprintf("ยาวเกิน %d ตัวอักษร", r.MaxLength)
+	}
+
+	if len(r.Allowed) > 0 {
+		allowed := false
+		for _, a := range r.Allowed {
+			if value == a {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Sprintf("ค่า %q ไม่อยู่ในค่าที่อนุญาต (%s)", value, strings.Join(r.Allowed, ", "))
+		}
+	}
+
+	return ""
+}
+
+// validateRecord ตรวจสอบทุกคอลัมน์ของแถว โดย rules[i] ใช้กับ record[i]
+func validateRecord(record []string, rules []ColumnRule) []Violation {
+	var violations []Violation
+	for i, rule := range rules {
+		if msg := rule.Check(record[i]); msg != "" {
+			violations = append(violations, Violation{Column: rule.Name, Message: msg})
+		}
+	}
+	return violations
+}
+
+// rejectFilePath คืน path ของไฟล์ reject (<file>.rejects.csv) ในโฟลเดอร์ที่กำหนด
+func rejectFilePath(filePath, rejectDir string) string {
+	if rejectDir == "" {
+		rejectDir = filepath.Dir(filePath)
+	}
+	return filepath.Join(rejectDir, filepath.Base(filePath)+".rejects.csv")
+}
@@ -0,0 +1,92 @@
+package process
+
+import (
+	"mcmc/config"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumnRuleCheck(t *testing.T) {
+	status := ColumnRule{Name: "Status", Kind: KindText, MaxLength: 10, Allowed: []string{"Open", "Closed"}}
+
+	tests := []struct {
+		name  string
+		rule  ColumnRule
+		value string
+		want  string // ข้อความที่ต้องพบในผลลัพธ์ ("" = ต้องผ่าน)
+	}{
+		{name: "ค่าว่างไม่บังคับ", rule: ColumnRule{Kind: KindNumber}, value: "", want: ""},
+		{name: "ค่าว่างที่บังคับ", rule: ColumnRule{Kind: KindText, Required: true}, value: "  ", want: "ต้องระบุค่า"},
+		{name: "ตัวเลขมีตัวคั่นหลักพัน", rule: ColumnRule{Kind: KindNumber}, value: "1,234.50", want: ""},
+		{name: "ตัวเลขไม่ถูกต้อง", rule: ColumnRule{Kind: KindNumber}, value: "12a", want: "ต้องเป็นตัวเลข"},
+		{name: "จำนวนเต็ม", rule: ColumnRule{Kind: KindInteger}, value: "42", want: ""},
+		{name: "จำนวนเต็มในรูปทศนิยม", rule: ColumnRule{Kind: KindInteger}, value: "3.0", want: ""},
+		{name: "จำนวนเต็มมีเศษ", rule: ColumnRule{Kind: KindInteger}, value: "3.5", want: "ต้องเป็นจำนวนเต็ม"},
+		{name: "วันที่", rule: ColumnRule{Kind: KindDate}, value: "2025-05-14 10:30:00", want: ""},
+		{name: "วันที่แบบวัน/เดือน/ปี", rule: ColumnRule{Kind: KindDate}, value: "14/05/2025", want: ""},
+		{name: "วันที่ไม่ถูกต้อง", rule: ColumnRule{Kind: KindDate}, value: "2025-13-01", want: "รูปแบบวันที่ไม่ถูกต้อง"},
+		{name: "ความยาวนับเป็นตัวอักษร", rule: ColumnRule{Kind: KindText, MaxLength: 4}, value: "ทดสอบ", want: "ยาวเกิน 4 ตัวอักษร"},
+		{name: "ความยาวพอดี", rule: ColumnRule{Kind: KindText, MaxLength: 5}, value: "ทดสอบ", want: ""},
+		{name: "ค่าที่อนุญาต", rule: status, value: "Open", want: ""},
+		{name: "ค่าที่ไม่อนุญาต", rule: status, value: "open", want: "ไม่อยู่ในค่าที่อนุญาต"},
+		{name: "ค่าว่างไม่ตรวจค่าที่อนุญาต", rule: status, value: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rule.Check(tt.value)
+			if tt.want == "" && got != "" || tt.want != "" && !strings.Contains(got, tt.want) {
+				t.Errorf("Check(%q) = %q ต้องการ %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRecord(t *testing.T) {
+	rules := summaryRules(config.ValidationConfig{})
+
+	tests := []struct {
+		name   string
+		record []string
+		want   []string // คอลัมน์ที่ไม่ผ่าน
+	}{
+		{name: "ผ่านทุกคอลัมน์", record: []string{"2", "5"}, want: nil},
+		{name: "ไม่ผ่านหนึ่งคอลัมน์", record: []string{"2", "x"}, want: []string{"ItemCount"}},
+		{name: "ไม่ผ่านทุกคอลัมน์", record: []string{"", "1.5"}, want: []string{"HeaderCount", "ItemCount"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range validateRecord(tt.record, rules) {
+				got = append(got, v.Column)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateRecord(%q) ไม่ผ่านที่ %v ต้องการ %v", tt.record, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRejectFilePath(t *testing.T) {
+	file := filepath.Join("in", "saleorder_item_20250514.csv")
+
+	tests := []struct {
+		name      string
+		rejectDir string
+		want      string
+	}{
+		{name: "โฟลเดอร์เดียวกับไฟล์", rejectDir: "", want: filepath.Join("in", "saleorder_item_20250514.csv.rejects.csv")},
+		{name: "โฟลเดอร์ที่กำหนด", rejectDir: "rejects", want: filepath.Join("rejects", "saleorder_item_20250514.csv.rejects.csv")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rejectFilePath(file, tt.rejectDir); got != tt.want {
+				t.Errorf("rejectFilePath(%q, %q) = %q ต้องการ %q", file, tt.rejectDir, got, tt.want)
+			}
+		})
+	}
+}
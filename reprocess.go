@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mcmc/database"
+	"mcmc/process"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// reprocess ประมวลผลไฟล์ (หรือชุดไฟล์ตาม batch ID เมื่อ batch = true) ที่เคยบันทึกแล้วซ้ำ
+// ข้อมูลเดิมของไฟล์ถูกลบและบันทึกใหม่ภายใน transaction เดียว ถ้าล้มเหลวข้อมูลเดิมจะยังอยู่
+// ไฟล์ถูกดาวน์โหลดใหม่จาก SFTP server หรืออ่านจากโฟลเดอร์ fromDir ถ้ากำหนด
+func (s *service) reprocess(ctx context.Context, names []string, batch bool, fromDir string) error {
+	if fromDir == "" {
+		if err := s.connectSFTP(); err != nil {
+			return err
+		}
+	}
+
+	// ใช้ lock เดียวกับรอบปกติ เพื่อไม่ให้ลบและบันทึกซ้อนกับ instance ที่กำลังประมวลผลอยู่
+	lock, err := s.db.AcquireRunLock(s.cfg.Cron.LockName, s.cfg.Cron.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			log.Printf("ข้อผิดพลาด: %v", err)
+		}
+	}()
+
+	attempts := s.newAttemptLog()
+	failed := 0
+	for _, name := range names {
+		if ctx.Err() != nil {
+			log.Printf("ได้รับสัญญาณหยุด ไม่เริ่มรายการถัดไป")
+			break
+		}
+
+		if batch {
+			err = s.reprocessBatch(attempts, name, fromDir)
+		} else {
+			err = s.reprocessFile(attempts, name, fromDir)
+		}
+		if err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("ประมวลผลซ้ำไม่สำเร็จ %d จาก %d รายการ", failed, len(names))
+	}
+	return nil
+}
+
+// reprocessFile ประมวลผลไฟล์ชื่อ name ซ้ำ
+func (s *service) reprocessFile(attempts *database.AttemptLog, name, fromDir string) error {
+	log.Printf("กำลังประมวลผลไฟล์ %s ซ้ำ...", name)
+	startAttempts(attempts, name)
+
+	var localFilePaths []string
+	defer func() {
+		if fromDir == "" {
+			removeFiles(&localFilePaths)
+		}
+	}()
+
+	localFilePath, remoteFilePath, err := s.fetch(attempts, name, fromDir)
+	if err != nil {
+		log.Printf("ประมวลผลไฟล์ %s ไม่สำเร็จ: %v", name, err)
+		if err := attempts.Finish(name, database.AttemptFailed, database.AttemptStats{}, err.Error()); err != nil {
+			log.Printf("ไม่สามารถบันทึกประวัติการประมวลผลได้: %v", err)
+		}
+		return err
+	}
+	localFilePaths = append(localFilePaths, localFilePath)
+
+	if feed := s.registry.Match(name); feed != nil {
+		err = loadFeedFile(s.cfg, s.db, attempts, feed, localFilePath, name, true)
+	} else {
+		err = loadFile(s.cfg, s.db, attempts, localFilePath, name, true)
+	}
+	if fromDir == "" {
+		archiveFiles(s.cfg, s.sftp, []string{remoteFilePath}, localFilePaths, err)
+	}
+	return err
+}
+
+// reprocessBatch ประมวลผลชุดไฟล์ batchID ซ้ำทั้งชุด (ต้องมีไฟล์ครบทุกประเภท)
+func (s *service) reprocessBatch(attempts *database.AttemptLog, batchID, fromDir string) error {
+	log.Printf("กำลังประมวลผลชุดไฟล์ %s ซ้ำ...", batchID)
+
+	batch, err := s.findBatch(batchID, fromDir)
+	if err != nil {
+		log.Printf("ประมวลผลชุดไฟล์ %s ไม่สำเร็จ: %v", batchID, err)
+		return err
+	}
+	fileNames := batchFileNames(s.cfg, batch)
+	startAttempts(attempts, fileNames...)
+
+	var localFilePaths []string
+	defer func() {
+		if fromDir == "" {
+			removeFiles(&localFilePaths)
+		}
+	}()
+
+	var remoteFilePaths []string
+	for _, name := range fileNames {
+		localFilePath, remoteFilePath, err := s.fetch(attempts, name, fromDir)
+		if err != nil {
+			return failBatch(s.db, attempts, batch.ID, fileNames, nil, err, name)
+		}
+		localFilePaths = append(localFilePaths, localFilePath)
+		remoteFilePaths = append(remoteFilePaths, remoteFilePath)
+	}
+
+	err = loadBatch(s.cfg, s.db, attempts, batch.ID, fileNames, localFilePaths, true)
+	if fromDir == "" {
+		archiveFiles(s.cfg, s.sftp, remoteFilePaths, localFilePaths, err)
+	}
+	return err
+}
+
+// findBatch หาไฟล์ของชุด batchID จาก SFTP server หรือจากโฟลเดอร์ fromDir
+func (s *service) findBatch(batchID, fromDir string) (*process.Batch, error) {
+	var files []os.FileInfo
+	if fromDir != "" {
+		entries, err := os.ReadDir(fromDir)
+		if err != nil {
+			return nil, fmt.Errorf("ไม่สามารถอ่านโฟลเดอร์ %s ได้: %v", fromDir, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return nil, fmt.Errorf("ไม่สามารถอ่านข้อมูลไฟล์ %s ได้: %v", entry.Name(), err)
+			}
+			files = append(files, info)
+		}
+	} else {
+		// ไฟล์ที่ประมวลผลแล้วอาจถูกย้ายไปโฟลเดอร์ของ archive ถ้าชื่อซ้ำกันใช้ไฟล์ที่พบก่อน
+		seen := map[string]bool{}
+		for _, dir := range remoteDirs(s.cfg) {
+			if dir != s.cfg.SFTP.RemotePath {
+				exists, err := s.sftp.Exists(dir)
+				if err != nil {
+					return nil, err
+				}
+				if !exists {
+					continue
+				}
+			}
+			for _, prefix := range s.cfg.App.FileTypes {
+				matched, err := s.sftp.ListFilesByPrefixIn(dir, prefix)
+				if err != nil {
+					return nil, err
+				}
+				for _, file := range matched {
+					if !seen[file.Name()] {
+						seen[file.Name()] = true
+						files = append(files, file)
+					}
+				}
+			}
+		}
+	}
+
+	for _, batch := range process.GroupBatches(s.cfg.App.FileTypes, files) {
+		if batch.ID != batchID {
+			continue
+		}
+		if missing := batch.Missing(s.cfg.App.FileTypes); len(missing) > 0 {
+			return nil, fmt.Errorf("ชุดไฟล์ %s ไม่ครบ (ขาด %s)", batchID, strings.Join(missing, ", "))
+		}
+		return batch, nil
+	}
+	return nil, errors.New("ไม่พบชุดไฟล์ " + batchID)
+}
+
+// fetch คืน path ของไฟล์ name บนเครื่อง โดยดาวน์โหลดจาก SFTP server หรือใช้ไฟล์ในโฟลเดอร์ fromDir
+// และคืน path บน SFTP server ที่พบไฟล์ (ค้นหาใน sftp.remote_path ก่อน แล้วจึงโฟลเดอร์ของ archive)
+func (s *service) fetch(attempts *database.AttemptLog, name, fromDir string) (string, string, error) {
+	if fromDir != "" {
+		localFilePath := filepath.Join(fromDir, name)
+		if _, err := os.Stat(localFilePath); err != nil {
+			return "", "", fmt.Errorf("ไม่พบไฟล์ %s: %v", localFilePath, err)
+		}
+		return localFilePath, "", nil
+	}
+
+	var remoteFilePath string
+	for _, dir := range remoteDirs(s.cfg) {
+		exists, err := s.sftp.Exists(dir + "/" + name)
+		if err != nil {
+			return "", "", err
+		}
+		if exists {
+			remoteFilePath = dir + "/" + name
+			break
+		}
+	}
+	if remoteFilePath == "" {
+		return "", "", fmt.Errorf("ไม่พบไฟล์ %s บน SFTP server", name)
+	}
+	localFilePath := filepath.Join(s.cfg.App.DownloadDir, name)
+
+	log.Printf("กำลังดาวน์โหลดไฟล์ %s...", remoteFilePath)
+	downloaded, err := s.sftp.DownloadFile(remoteFilePath, localFilePath)
+	if err != nil {
+		return "", "", fmt.Errorf("ไม่สามารถดาวน์โหลดไฟล์ %s ได้: %v", name, err)
+	}
+	recordDownload(attempts, name, downloaded)
+	return localFilePath, remoteFilePath, nil
+}
@@ -0,0 +1,101 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"mcmc/config"
+	"time"
+)
+
+// Policy กำหนดจำนวนครั้งและระยะรอของการลองใหม่
+// ระยะรอเพิ่มเป็นสองเท่าทุกครั้งจาก BaseDelay แต่ไม่เกิน MaxDelay และสุ่มลดลงไม่เกินครึ่งหนึ่ง (jitter)
+type Policy struct {
+	Attempts  int // จำนวนครั้งสูงสุดรวมครั้งแรก (น้อยกว่า 1 = ลองครั้งเดียว)
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// NewPolicy สร้าง Policy จากการตั้งค่า cron (ระยะรอสูงสุดคือ retry_interval)
+func NewPolicy(cfg config.CronConfig) Policy {
+	return Policy{
+		Attempts:  cfg.RetryAttempts,
+		BaseDelay: cfg.RetryBaseDelay,
+		MaxDelay:  cfg.RetryInterval,
+	}
+}
+
+// permanentError คือ error ที่ลองใหม่แล้วก็จะล้มเหลวเหมือนเดิม เช่น รหัสผ่านผิดหรือ host key ไม่ตรง
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent ทำเครื่องหมายว่า err ไม่ควรลองใหม่ (คืน nil ถ้า err เป็น nil)
+func Permanent(err error) error {
+	if err == nil || IsPermanent(err) {
+		return err
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent ตรวจสอบว่า err ไม่ควรลองใหม่ (ถูกทำเครื่องหมายด้วย Permanent หรือ context ถูกยกเลิก)
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Do เรียก fn จนสำเร็จ ลองใหม่ตาม p เมื่อ error เป็นแบบชั่วคราว
+// หยุดทันทีเมื่อ error เป็นแบบถาวรหรือ ctx ถูกยกเลิก name ใช้แสดงใน log และข้อความ error
+func Do(ctx context.Context, p Policy, name string, fn func() error) error {
+	attempts := p.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if IsPermanent(err) || attempt >= attempts || ctx.Err() != nil {
+			break
+		}
+
+		delay := p.delay(attempt)
+		log.Printf("%s ไม่สำเร็จ (ครั้งที่ %d/%d) จะลองใหม่ใน %s: %v", name, attempt, attempts, delay.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%s ไม่สำเร็จ (ยกเลิกระหว่างรอลองใหม่): %w", name, err)
+		case <-timer.C:
+		}
+	}
+
+	if attempts > 1 && !IsPermanent(err) {
+		return fmt.Errorf("%s ไม่สำเร็จหลังลอง %d ครั้ง: %w", name, attempts, err)
+	}
+	return err
+}
+
+// delay คืนระยะรอก่อนลองครั้งที่ attempt+1
+func (p Policy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	// สุ่มในช่วง [d/2, d] เพื่อไม่ให้หลาย instance ลองใหม่พร้อมกัน
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,142 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errTemporary = errors.New("ชั่วคราว")
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name      string
+		attempts  int
+		results   []error // error ที่ fn คืนในแต่ละครั้ง (ครั้งที่เกินคืน nil)
+		wantCalls int
+		wantErr   string // ข้อความที่ error ต้องมี ("" = ต้องสำเร็จ)
+	}{
+		{name: "สำเร็จครั้งแรก", attempts: 3, wantCalls: 1},
+		{name: "สำเร็จในครั้งที่สาม", attempts: 3, results: []error{errTemporary, errTemporary}, wantCalls: 3},
+		{name: "ล้มเหลวครบทุกครั้ง", attempts: 3, results: []error{errTemporary, errTemporary, errTemporary}, wantCalls: 3, wantErr: "หลังลอง 3 ครั้ง"},
+		{name: "error ถาวรไม่ลองใหม่", attempts: 3, results: []error{Permanent(errTemporary)}, wantCalls: 1, wantErr: errTemporary.Error()},
+		{name: "context ถูกยกเลิกไม่ลองใหม่", attempts: 3, results: []error{context.Canceled}, wantCalls: 1, wantErr: context.Canceled.Error()},
+		{name: "attempts น้อยกว่า 1 ลองครั้งเดียว", attempts: 0, results: []error{errTemporary, errTemporary}, wantCalls: 1, wantErr: errTemporary.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := Do(context.Background(), Policy{Attempts: tt.attempts}, "ทดสอบ", func() error {
+				calls++
+				if calls <= len(tt.results) {
+					return tt.results[calls-1]
+				}
+				return nil
+			})
+
+			if calls != tt.wantCalls {
+				t.Errorf("เรียก fn %d ครั้ง ต้องการ %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Do = %v ต้องการ nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Do = %v ต้องการ error ที่มี %q", err, tt.wantErr)
+			}
+			if last := tt.results[tt.wantCalls-1]; !errors.Is(err, last) {
+				t.Errorf("Do = %v ต้อง wrap error จาก fn", err)
+			}
+		})
+	}
+}
+
+func TestDoCancelWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	calls := 0
+	start := time.Now()
+	err := Do(ctx, Policy{Attempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}, "ทดสอบ", func() error {
+		calls++
+		return errTemporary
+	})
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Do ใช้เวลา %s หลัง context ถูกยกเลิก", elapsed)
+	}
+	if calls != 1 {
+		t.Errorf("เรียก fn %d ครั้ง ต้องการ 1", calls)
+	}
+	if err == nil || !errors.Is(err, errTemporary) || !strings.Contains(err.Error(), "ยกเลิกระหว่างรอลองใหม่") {
+		t.Errorf("Do = %v ต้องการ error ที่ยกเลิกระหว่างรอ", err)
+	}
+}
+
+func TestPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		attempt int
+		max     time.Duration // ระยะรอก่อนสุ่ม (ผลต้องอยู่ในช่วง [max/2, max])
+	}{
+		{name: "ครั้งแรกใช้ base", policy: Policy{BaseDelay: time.Second, MaxDelay: time.Minute}, attempt: 1, max: time.Second},
+		{name: "เพิ่มเป็นสองเท่า", policy: Policy{BaseDelay: time.Second, MaxDelay: time.Minute}, attempt: 3, max: 4 * time.Second},
+		{name: "ไม่เกิน MaxDelay", policy: Policy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}, attempt: 10, max: 5 * time.Second},
+		{name: "ครั้งที่มากไม่ล้น", policy: Policy{BaseDelay: time.Second, MaxDelay: time.Minute}, attempt: 100, max: time.Minute},
+		{name: "ไม่กำหนด MaxDelay", policy: Policy{BaseDelay: time.Second}, attempt: 4, max: 8 * time.Second},
+		{name: "base เป็นศูนย์ไม่รอ", policy: Policy{MaxDelay: time.Minute}, attempt: 3, max: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				if got := tt.policy.delay(tt.attempt); got < tt.max/2 || got > tt.max {
+					t.Fatalf("delay(%d) = %s ต้องอยู่ในช่วง [%s, %s]", tt.attempt, got, tt.max/2, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	permanent := Permanent(errTemporary)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "error ทั่วไป", err: errTemporary, want: false},
+		{name: "Permanent", err: permanent, want: true},
+		{name: "Permanent ที่ถูก wrap", err: fmt.Errorf("เชื่อมต่อ: %w", permanent), want: true},
+		{name: "context.Canceled", err: context.Canceled, want: true},
+		{name: "context.DeadlineExceeded ที่ถูก wrap", err: fmt.Errorf("รอ: %w", context.DeadlineExceeded), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPermanent(tt.err); got != tt.want {
+				t.Errorf("IsPermanent(%v) = %v ต้องการ %v", tt.err, got, tt.want)
+			}
+		})
+	}
+
+	if Permanent(nil) != nil {
+		t.Errorf("Permanent(nil) ต้องคืน nil")
+	}
+	if Permanent(permanent) != permanent {
+		t.Errorf("Permanent ต้องไม่ห่อ error ที่เป็นแบบถาวรอยู่แล้วซ้ำ")
+	}
+	if !errors.Is(permanent, errTemporary) || permanent.Error() != errTemporary.Error() {
+		t.Errorf("Permanent(%v) = %v ต้อง unwrap เป็น error เดิม", errTemporary, permanent)
+	}
+}
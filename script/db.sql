@@ -1,13 +1,71 @@
-CREATE TABLE file_processing_logs (
+-- โครงสร้างตารางสำหรับ SQL Server (อ้างอิง) โปรแกรมสร้างตารางเองด้วย migration ใน database/migrations/<driver>
+-- ผ่าน "mcmc migrate up" หรือตอนเริ่มทำงานเมื่อ database.auto_migrate = true
+
+CREATE TABLE file_processing_attempts (
     id INT IDENTITY(1,1) PRIMARY KEY,
     filename NVARCHAR(255) NOT NULL,
+    attempt INT NOT NULL,
+    run_id NVARCHAR(64) NOT NULL,
+    host NVARCHAR(255),
+    status NVARCHAR(10) NOT NULL CHECK (status IN ('running', 'success', 'failed')),
+    started_at DATETIME2 NOT NULL,
+    finished_at DATETIME2,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    rows_read INT NOT NULL DEFAULT 0,
+    rows_written INT NOT NULL DEFAULT 0,
+    rows_rejected INT NOT NULL DEFAULT 0,
+    load_mode NVARCHAR(20),
+    inserted_count INT NOT NULL DEFAULT 0,
+    updated_count INT NOT NULL DEFAULT 0,
+    unchanged_count INT NOT NULL DEFAULT 0,
+    error_message NVARCHAR(MAX),
+    sha256 NVARCHAR(64),
+    CONSTRAINT UQ_file_processing_attempts_filename_attempt UNIQUE (filename, attempt)
+);
+
+CREATE INDEX IX_file_processing_attempts_run_id ON file_processing_attempts (run_id);
+
+CREATE VIEW file_processing_status AS
+SELECT a.filename, a.attempt AS last_attempt, a.status, a.started_at, a.finished_at, a.error_message,
+    s.attempt_count, ls.finished_at AS last_success_at
+FROM file_processing_attempts a
+JOIN (
+    SELECT filename, MAX(attempt) AS last_attempt, COUNT(*) AS attempt_count,
+        MAX(CASE WHEN status = 'success' THEN attempt END) AS last_success_attempt
+    FROM file_processing_attempts
+    GROUP BY filename
+) s ON s.filename = a.filename AND s.last_attempt = a.attempt
+LEFT JOIN file_processing_attempts ls ON ls.filename = s.filename AND ls.attempt = s.last_success_attempt;
+
+CREATE TABLE batch_processing_logs (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    batch_id NVARCHAR(255) NOT NULL,
     status NVARCHAR(10) NOT NULL CHECK (status IN ('success', 'failed')),
+    file_count INT NOT NULL DEFAULT 0,
     record_count INT NOT NULL DEFAULT 0,
     error_message NVARCHAR(MAX),
-    created_at DATETIME2 NOT NULL,
-    CONSTRAINT UQ_filename UNIQUE (filename)
+    created_at DATETIME2 NOT NULL
 );
 
+CREATE INDEX IX_batch_processing_logs_batch_id ON batch_processing_logs (batch_id);
+
+CREATE TABLE batch_reconciliations (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    batch_id NVARCHAR(255) NOT NULL,
+    status NVARCHAR(20) NOT NULL CHECK (status IN ('matched', 'mismatched')),
+    expected_header_count INT NOT NULL DEFAULT 0,
+    actual_header_count INT NOT NULL DEFAULT 0,
+    rejected_header_count INT NOT NULL DEFAULT 0,
+    expected_item_count INT NOT NULL DEFAULT 0,
+    actual_item_count INT NOT NULL DEFAULT 0,
+    rejected_item_count INT NOT NULL DEFAULT 0,
+    message NVARCHAR(MAX),
+    created_at DATETIME2 NOT NULL
+);
+
+CREATE INDEX IX_batch_reconciliations_batch_id ON batch_reconciliations (batch_id);
+
 CREATE TABLE saleorder_header (
     id INT IDENTITY(1,1) PRIMARY KEY,
     doc_no NVARCHAR(50) NOT NULL,
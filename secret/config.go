@@ -0,0 +1,64 @@
+package secret
+
+import (
+	"fmt"
+	"mcmc/config"
+	"os"
+	"strings"
+	"sync"
+)
+
+// vaultOnce ลงทะเบียน provider vault-kv จาก config ครั้งแรกที่เรียก ResolveConfig
+var vaultOnce sync.Once
+
+// ResolveConfig คืนสำเนาของ config ที่แปลง secret reference ทั้งหมดเป็นค่าจริงแล้ว
+// ควรเรียกทุกครั้งก่อนเชื่อมต่อ เพื่อให้ได้ค่า secret ล่าสุด
+func ResolveConfig(cfg *config.Config) (*config.Config, error) {
+	vaultOnce.Do(func() {
+		Register("vault-kv", configVault{
+			address: firstNonEmpty(cfg.Secrets.VaultAddress, os.Getenv("VAULT_ADDR")),
+			token:   firstNonEmpty(cfg.Secrets.VaultToken, os.Getenv("VAULT_TOKEN")),
+		})
+	})
+
+	var err error
+	resolved := *cfg
+	if resolved.Database.Password, err = Resolve(cfg.Database.Password); err != nil {
+		return nil, fmt.Errorf("database.password: %v", err)
+	}
+	if resolved.SFTP.Password, err = Resolve(cfg.SFTP.Password); err != nil {
+		return nil, fmt.Errorf("sftp.password: %v", err)
+	}
+	if resolved.SFTP.PrivateKeyPassphrase, err = Resolve(cfg.SFTP.PrivateKeyPassphrase); err != nil {
+		return nil, fmt.Errorf("sftp.private_key_passphrase: %v", err)
+	}
+
+	return &resolved, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// configVault คือ VaultProvider ตาม secrets ใน config ที่อ่าน token ใหม่ทุกครั้ง
+// เพื่อให้ใช้ token ล่าสุดเมื่อไฟล์หรือ environment ของ token ถูกเปลี่ยน
+type configVault struct {
+	address string
+	token   string
+}
+
+func (v configVault) Resolve(ref string) (string, error) {
+	if strings.HasPrefix(v.token, "vault-kv:") {
+		return "", fmt.Errorf("secrets.vault_token ต้องไม่อ่านจาก Vault")
+	}
+	token, err := Resolve(v.token)
+	if err != nil {
+		return "", fmt.Errorf("secrets.vault_token: %v", err)
+	}
+	return VaultProvider{Address: v.address, Token: token}.Resolve(ref)
+}
@@ -0,0 +1,92 @@
+package secret
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider ดึงค่า secret จากแหล่งเก็บตาม reference ที่ได้รับ (ส่วนหลัง scheme:)
+type Provider interface {
+	Resolve(ref string) (string, error)
+}
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{
+		"file": FileProvider{},
+		"env":  EnvProvider{},
+	}
+)
+
+// Register ลงทะเบียน provider สำหรับ scheme ที่กำหนด (แทนที่ของเดิมถ้ามี)
+func Register(scheme string, p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[scheme] = p
+}
+
+// Resolve แปลงค่าในรูปแบบ scheme:ref เป็นค่า secret จริง
+// ถ้า scheme ไม่ได้ลงทะเบียนไว้จะถือว่าค่านั้นเป็นค่าจริงอยู่แล้ว
+func Resolve(value string) (string, error) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return value, nil
+	}
+
+	mu.RLock()
+	p, found := providers[scheme]
+	mu.RUnlock()
+	if !found {
+		return value, nil
+	}
+
+	resolved, err := p.Resolve(ref)
+	if err != nil {
+		return "", fmt.Errorf("ไม่สามารถดึง secret จาก %s ได้: %v", scheme, err)
+	}
+	return resolved, nil
+}
+
+// Redact แทนที่ค่า secret ที่ปรากฏในข้อความด้วย *** รวมถึงรูปที่ถูก encode ไว้ใน URL หรือ DSN
+func Redact(text string, secrets ...string) string {
+	var forms []string
+	for _, s := range secrets {
+		if s == "" {
+			continue
+		}
+		userinfo := strings.TrimPrefix(url.UserPassword("", s).String(), ":")
+		forms = append(forms, s, url.QueryEscape(s), url.PathEscape(s), userinfo)
+	}
+	// แทนที่รูปที่ยาวกว่าก่อน เพื่อไม่ให้ค่าเดิมที่อยู่ในรูปที่ encode แล้วถูกแทนที่ไปบางส่วน
+	sort.Slice(forms, func(i, j int) bool { return len(forms[i]) > len(forms[j]) })
+	for _, f := range forms {
+		text = strings.ReplaceAll(text, f, "***")
+	}
+	return text
+}
+
+// FileProvider อ่าน secret จากไฟล์ (เช่น file:/run/secrets/db_pw)
+type FileProvider struct{}
+
+func (FileProvider) Resolve(ref string) (string, error) {
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", fmt.Errorf("ไม่สามารถอ่านไฟล์ %s ได้: %v", ref, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvProvider อ่าน secret จาก environment variable (เช่น env:DB_PW)
+type EnvProvider struct{}
+
+func (EnvProvider) Resolve(ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("ไม่พบ environment variable %s", ref)
+	}
+	return value, nil
+}
@@ -0,0 +1,155 @@
+package secret
+
+import (
+	"encoding/json"
+	"mcmc/config"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testVaultToken คือ token ที่ Vault ทดสอบยอมรับ
+const testVaultToken = "s.test"
+
+// newTestVault สร้าง Vault ทดสอบที่มี secret แบบ KV v1 ที่ kv/mcmc และแบบ KV v2 ที่ secret/data/mcmc
+func newTestVault(t *testing.T) *httptest.Server {
+	t.Helper()
+	data := map[string]interface{}{
+		"/v1/kv/mcmc":          map[string]interface{}{"data": map[string]interface{}{"db_password": "v1-secret"}},
+		"/v1/secret/data/mcmc": map[string]interface{}{"data": map[string]interface{}{"data": map[string]interface{}{"db_password": "v2-secret"}}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != testVaultToken {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		body, ok := data[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "db_pw")
+	if err := os.WriteFile(secretFile, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MCMC_TEST_SECRET", "env-secret")
+	Register("vault-kv", VaultProvider{Address: newTestVault(t).URL, Token: testVaultToken})
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "file", value: "file:" + secretFile, want: "file-secret"},
+		{name: "env", value: "env:MCMC_TEST_SECRET", want: "env-secret"},
+		{name: "vault-kv v1", value: "vault-kv:kv/mcmc#db_password", want: "v1-secret"},
+		{name: "vault-kv v2", value: "vault-kv:secret/data/mcmc#db_password", want: "v2-secret"},
+		{name: "ค่าจริงที่ไม่มี scheme", value: "plain-secret", want: "plain-secret"},
+		{name: "ค่าว่าง", value: "", want: ""},
+		{name: "ไม่รู้จัก scheme ถือเป็นค่าจริง", value: "p@ss:word", want: "p@ss:word"},
+		{name: "ไม่พบไฟล์", value: "file:" + filepath.Join(dir, "missing"), wantErr: true},
+		{name: "ไม่พบ environment variable", value: "env:MCMC_TEST_MISSING", wantErr: true},
+		{name: "vault-kv ไม่พบ key", value: "vault-kv:kv/mcmc#missing", wantErr: true},
+		{name: "vault-kv ไม่พบ path", value: "vault-kv:kv/other#db_password", wantErr: true},
+		{name: "vault-kv ไม่มี key ใน reference", value: "vault-kv:kv/mcmc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Resolve(%q) = %q ต้องการ error", tt.value, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Resolve(%q) = %q, %v ต้องการ %q", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveConfig(t *testing.T) {
+	// เริ่มใหม่ให้ ResolveConfig ลงทะเบียน vault-kv จาก config ของ test นี้
+	vaultOnce = sync.Once{}
+	t.Cleanup(func() { vaultOnce = sync.Once{} })
+
+	t.Setenv("MCMC_TEST_DB_PW", "db-secret")
+	t.Setenv("MCMC_TEST_VAULT_TOKEN", testVaultToken)
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{Password: "env:MCMC_TEST_DB_PW"},
+		SFTP: config.SFTPConfig{
+			Password:             "vault-kv:secret/data/mcmc#db_password",
+			PrivateKeyPassphrase: "plain-passphrase",
+		},
+		Secrets: config.SecretsConfig{
+			VaultAddress: newTestVault(t).URL,
+			VaultToken:   "env:MCMC_TEST_VAULT_TOKEN",
+		},
+	}
+
+	resolved, err := ResolveConfig(cfg)
+	if err != nil {
+		t.Fatalf("ResolveConfig: %v", err)
+	}
+	if resolved.Database.Password != "db-secret" || resolved.SFTP.Password != "v2-secret" || resolved.SFTP.PrivateKeyPassphrase != "plain-passphrase" {
+		t.Errorf("ResolveConfig = database %q, sftp %q, passphrase %q", resolved.Database.Password, resolved.SFTP.Password, resolved.SFTP.PrivateKeyPassphrase)
+	}
+	if cfg.Database.Password != "env:MCMC_TEST_DB_PW" {
+		t.Errorf("ResolveConfig แก้ไข config เดิมเป็น %q", cfg.Database.Password)
+	}
+
+	// token ถูกอ่านใหม่ทุกครั้ง เมื่อ token เปลี่ยนต้องใช้ค่าใหม่
+	t.Setenv("MCMC_TEST_VAULT_TOKEN", "s.revoked")
+	if _, err := ResolveConfig(cfg); err == nil || !strings.HasPrefix(err.Error(), "sftp.password") {
+		t.Errorf("ResolveConfig หลังเปลี่ยน token error = %v ต้องการ error ของ sftp.password", err)
+	}
+
+	cfg.SFTP.Password = ""
+	cfg.SFTP.PrivateKeyPassphrase = "env:MCMC_TEST_MISSING"
+	if _, err := ResolveConfig(cfg); err == nil || !strings.HasPrefix(err.Error(), "sftp.private_key_passphrase") {
+		t.Errorf("ResolveConfig error = %v ต้องการ error ของ sftp.private_key_passphrase", err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	const password = "p@ss w/rd+1"
+	dsn := (&url.URL{Scheme: "sqlserver", User: url.UserPassword("mcmc", password), Host: "db:1433"}).String()
+
+	tests := []struct {
+		name    string
+		text    string
+		secrets []string
+		want    string
+	}{
+		{name: "ค่าเดิม", text: "login failed: " + password, secrets: []string{password}, want: "login failed: ***"},
+		{name: "userinfo ใน DSN", text: "dial " + dsn, secrets: []string{password}, want: "dial sqlserver://mcmc:***@db:1433"},
+		{name: "query escape", text: "password=" + url.QueryEscape(password), secrets: []string{password}, want: "password=***"},
+		{name: "path escape", text: "/" + url.PathEscape(password) + "/", secrets: []string{password}, want: "/***/"},
+		{name: "หลาย secret", text: "a=one b=two", secrets: []string{"one", "two"}, want: "a=*** b=***"},
+		{name: "ข้าม secret ว่าง", text: "nothing to hide", secrets: []string{""}, want: "nothing to hide"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.text, tt.secrets...); got != tt.want {
+				t.Errorf("Redact(%q) = %q ต้องการ %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
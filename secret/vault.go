@@ -0,0 +1,68 @@
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultProvider อ่าน secret จาก Vault KV (รองรับทั้ง v1 และ v2)
+// reference อยู่ในรูปแบบ path#key เช่น vault-kv:secret/data/mcmc#db_password
+type VaultProvider struct {
+	Address string
+	Token   string
+	Client  *http.Client
+}
+
+func (v VaultProvider) Resolve(ref string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("reference ต้องอยู่ในรูปแบบ path#key (%s)", ref)
+	}
+	if v.Address == "" {
+		return "", fmt.Errorf("ไม่ได้กำหนด Vault address")
+	}
+
+	client := v.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	url := strings.TrimRight(v.Address, "/") + "/v1/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("ไม่สามารถสร้าง request ไปยัง Vault ได้: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ไม่สามารถเชื่อมต่อกับ Vault ได้: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Vault ตอบกลับ %s สำหรับ %s", resp.Status, path)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("ไม่สามารถอ่านข้อมูลจาก Vault ได้: %v", err)
+	}
+
+	// KV v2 ซ้อนข้อมูลไว้ใน data.data
+	data := body.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+
+	value, ok := data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("ไม่พบ key %s ใน %s", key, path)
+	}
+	return value, nil
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mcmc/config"
+	"mcmc/database"
+	"mcmc/process"
+	"mcmc/retry"
+	"mcmc/secret"
+	"mcmc/sftp"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// service ถือการเชื่อมต่อฐานข้อมูลและ SFTP ไว้ตลอดอายุของโปรเซส และใช้ร่วมกันทุกรอบการทำงาน
+// migration ทำครั้งเดียว (ตอน start หรือรอบแรกที่เชื่อมต่อฐานข้อมูลได้) และดึง secret ล่าสุดพร้อมตรวจสอบการเชื่อมต่อก่อนทุกรอบ
+// ถ้าการเชื่อมต่อหลุดหรือ secret ถูกเปลี่ยนจะเชื่อมต่อใหม่
+type service struct {
+	cfg      *config.Config
+	policy   retry.Policy
+	db       *database.DB
+	sftp     *sftp.Client
+	registry *process.Registry
+	host     string // ชื่อเครื่องที่บันทึกไว้กับประวัติการประมวลผล
+	prepared bool   // prepareDB สำเร็จแล้ว
+
+	// ค่าการเชื่อมต่อ (แปลง secret แล้ว) ของ db และ sftp ที่ใช้อยู่ ใช้ตรวจว่า secret ถูกเปลี่ยนหรือไม่
+	dbConfig   config.DatabaseConfig
+	sftpConfig config.SFTPConfig
+
+	// ctx ของการเชื่อมต่อ SFTP (ใช้ระหว่างลองใหม่) ถูกยกเลิกเมื่อ close
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newService(cfg *config.Config) *service {
+	ctx, cancel := context.WithCancel(context.Background())
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return &service{
+		host:     host,
+		cfg:      cfg,
+		policy:   retry.NewPolicy(cfg.Cron),
+		registry: process.NewRegistry(cfg.Feeds),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// start เชื่อมต่อฐานข้อมูลแล้วเตรียมฐานข้อมูลด้วย prepareDB
+// การเชื่อมต่อ SFTP จะเกิดขึ้นตอนตรวจสอบก่อนรอบแรก เพื่อไม่ให้ SFTP ที่ใช้งานไม่ได้ชั่วคราวทำให้โปรแกรมหยุด
+func (s *service) start(ctx context.Context) error {
+	if err := s.connectDB(ctx); err != nil {
+		return err
+	}
+	return s.prepareDB()
+}
+
+// prepareDB ทำ migration (หรือตรวจสอบว่าไม่มี migration ค้างเมื่อปิด auto_migrate) และเตรียมตารางของ feed
+// ครั้งเดียวต่อโปรเซส ถ้าล้มเหลว healthCheck จะลองใหม่ก่อนรอบถัดไป
+func (s *service) prepareDB() error {
+	if s.cfg.Database.AutoMigrate {
+		if err := s.db.MigrateDB(); err != nil {
+			return err
+		}
+	} else {
+		pending, err := s.db.PendingMigrations()
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("ฐานข้อมูลยังไม่ได้ทำ migration %d เวอร์ชัน กรุณารัน \"mcmc migrate up\" ก่อน", pending)
+		}
+	}
+
+	if len(s.cfg.Feeds) > 0 {
+		if err := s.registry.EnsureTables(s.db); err != nil {
+			return fmt.Errorf("ไม่สามารถเตรียมตารางของ feed ได้: %v", err)
+		}
+	}
+	s.prepared = true
+	return nil
+}
+
+// close ปิดการเชื่อมต่อทั้งหมด
+func (s *service) close() {
+	s.cancel()
+	if s.sftp != nil {
+		if err := s.sftp.Close(); err != nil {
+			log.Printf("ไม่สามารถปิดการเชื่อมต่อ SFTP ได้: %v", err)
+		}
+	}
+	if s.db != nil {
+		if err := s.db.Close(); err != nil {
+			log.Printf("ไม่สามารถปิดการเชื่อมต่อฐานข้อมูลได้: %v", err)
+		}
+	}
+}
+
+// resolveSecrets คืนสำเนาของ config ที่แปลง secret reference เป็นค่าล่าสุดแล้ว
+func (s *service) resolveSecrets() (*config.Config, error) {
+	runCfg, err := secret.ResolveConfig(s.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถดึง secret ได้: %v", err)
+	}
+	return runCfg, nil
+}
+
+// connectDB สร้าง connection pool ใหม่ด้วย secret ล่าสุดแทนของเดิม
+func (s *service) connectDB(ctx context.Context) error {
+	runCfg, err := s.resolveSecrets()
+	if err != nil {
+		return err
+	}
+	return s.openDB(ctx, runCfg.Database)
+}
+
+// openDB สร้าง connection pool ใหม่ตาม cfg (แปลง secret แล้ว) แทนของเดิม
+func (s *service) openDB(ctx context.Context, cfg config.DatabaseConfig) error {
+	log.Println("กำลังเชื่อมต่อกับฐานข้อมูล...")
+	db, err := database.Connect(ctx, cfg, s.policy)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถเชื่อมต่อกับฐานข้อมูลได้: %v", err)
+	}
+	log.Println("เชื่อมต่อกับฐานข้อมูลสำเร็จ")
+
+	if s.db != nil {
+		s.db.Close()
+	}
+	s.db = db
+	s.dbConfig = cfg
+	return nil
+}
+
+// connectSFTP สร้างการเชื่อมต่อ SFTP ใหม่ด้วย secret ล่าสุดแทนของเดิม
+func (s *service) connectSFTP() error {
+	runCfg, err := s.resolveSecrets()
+	if err != nil {
+		return err
+	}
+	return s.openSFTP(runCfg.SFTP)
+}
+
+// openSFTP สร้างการเชื่อมต่อ SFTP ใหม่ตาม cfg (แปลง secret แล้ว) แทนของเดิม
+func (s *service) openSFTP(cfg config.SFTPConfig) error {
+	log.Println("กำลังเชื่อมต่อกับ SFTP server...")
+	client, err := sftp.Dial(s.ctx, cfg, s.policy)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถเชื่อมต่อกับ SFTP server ได้: %v", err)
+	}
+	log.Println("เชื่อมต่อกับ SFTP server สำเร็จ")
+
+	if s.sftp != nil {
+		s.sftp.Close()
+	}
+	s.sftp = client
+	s.sftpConfig = cfg
+	return nil
+}
+
+// newAttemptLog สร้าง AttemptLog ของรอบการทำงานใหม่ โดยใช้ connection pool ที่ไม่ผูกกับ context ของรอบ
+// เพื่อให้บันทึกผลได้แม้งานถูกยกเลิก
+func (s *service) newAttemptLog() *database.AttemptLog {
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	runID := time.Now().Format("20060102T150405") + "-" + hex.EncodeToString(buf)
+	return s.db.NewAttemptLog(runID, s.host)
+}
+
+// healthCheck ดึง secret ล่าสุดแล้วตรวจสอบการเชื่อมต่อฐานข้อมูลและ SFTP (เชื่อมต่อและเตรียมฐานข้อมูลถ้ายังไม่ได้ทำ)
+// เชื่อมต่อใหม่เมื่อใช้งานไม่ได้ หรือเมื่อค่าการเชื่อมต่อ (เช่น รหัสผ่านที่ถูกเปลี่ยน) ต่างจากที่ใช้อยู่
+// เพราะ connection pool และการเชื่อมต่อ SFTP ใหม่ที่เกิดขึ้นภายหลังยังใช้ค่าเดิมที่ได้ตอนสร้าง
+func (s *service) healthCheck(ctx context.Context) error {
+	runCfg, err := s.resolveSecrets()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case s.db == nil:
+		err = s.openDB(ctx, runCfg.Database)
+	case !reflect.DeepEqual(runCfg.Database, s.dbConfig):
+		log.Println("ค่าการเชื่อมต่อฐานข้อมูลเปลี่ยนไป กำลังเชื่อมต่อใหม่")
+		err = s.openDB(ctx, runCfg.Database)
+	default:
+		if pingErr := s.db.Ping(ctx); pingErr != nil {
+			log.Printf("การเชื่อมต่อฐานข้อมูลใช้งานไม่ได้ กำลังเชื่อมต่อใหม่: %v", pingErr)
+			err = s.openDB(ctx, runCfg.Database)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !s.prepared {
+		if err := s.prepareDB(); err != nil {
+			return err
+		}
+	}
+
+	if s.sftp == nil {
+		return s.openSFTP(runCfg.SFTP)
+	}
+	if !reflect.DeepEqual(runCfg.SFTP, s.sftpConfig) {
+		log.Println("ค่าการเชื่อมต่อ SFTP เปลี่ยนไป กำลังเชื่อมต่อใหม่")
+		return s.openSFTP(runCfg.SFTP)
+	}
+	if err := s.sftp.Check(); err != nil {
+		log.Printf("การเชื่อมต่อ SFTP ใช้งานไม่ได้ กำลังเชื่อมต่อใหม่: %v", err)
+		return s.openSFTP(runCfg.SFTP)
+	}
+	return nil
+}
+
+// run ทำงานหลักของการดาวน์โหลดและประมวลผลไฟล์หนึ่งรอบ
+// เมื่อ ctx ถูกยกเลิกจะไม่เริ่มชุดไฟล์ใหม่ และถ้างานที่ค้างอยู่ไม่เสร็จภายใน cron.shutdown_timeout
+// จะยกเลิกงานนั้น (rollback transaction และปิดการเชื่อมต่อ SFTP)
+func (s *service) run(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	cfg := s.cfg
+
+	startTime := time.Now()
+	log.Printf("เริ่มรันงานเวลา %s", startTime.Format("15:04:05"))
+
+	workCtx, cancel := withGracePeriod(ctx, cfg.Cron.ShutdownTimeout)
+	defer cancel()
+
+	if err := s.healthCheck(workCtx); err != nil {
+		return err
+	}
+	db := s.db.WithContext(workCtx)
+	sftpClient := s.sftp
+	attempts := s.newAttemptLog()
+	log.Printf("รหัสรอบการทำงาน %s", attempts.RunID())
+
+	// ขอ lock ระดับฐานข้อมูลเพื่อไม่ให้ instance อื่นประมวลผลชุดเดียวกันพร้อมกัน
+	lock, err := db.AcquireRunLock(cfg.Cron.LockName, cfg.Cron.LockTimeout)
+	if err != nil {
+		if errors.Is(err, database.ErrLockNotAcquired) {
+			log.Printf("ข้ามรอบนี้: %v", err)
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			log.Printf("ข้อผิดพลาด: %v", err)
+		}
+	}()
+
+	// ปิดการเชื่อมต่อเมื่องานถูกยกเลิก เพื่อไม่ให้การดาวน์โหลดที่ค้างอยู่รอต่อไป
+	stopClose := context.AfterFunc(workCtx, func() {
+		sftpClient.Close()
+	})
+	defer stopClose()
+
+	// สร้างโฟลเดอร์สำหรับเก็บไฟล์ที่ดาวน์โหลด
+	if _, err := os.Stat(cfg.App.DownloadDir); os.IsNotExist(err) {
+		if err := os.MkdirAll(cfg.App.DownloadDir, 0755); err != nil {
+			return fmt.Errorf("ไม่สามารถสร้างโฟลเดอร์ %s ได้: %v", cfg.App.DownloadDir, err)
+		}
+	}
+
+	// รวบรวมไฟล์ทุก prefix แล้วจัดกลุ่มเป็นชุดตาม batch ID
+	var files []os.FileInfo
+	processedFiles := map[string]bool{}
+	for _, prefix := range cfg.App.FileTypes {
+		log.Printf("กำลังค้นหาไฟล์ที่ขึ้นต้นด้วย %s...", prefix)
+
+		matched, err := sftpClient.ListFilesByPrefix(prefix)
+		if err != nil {
+			return err
+		}
+		files = append(files, matched...)
+
+		processed, err := db.ProcessedFilesByPrefix(prefix)
+		if err != nil {
+			return err
+		}
+		for name := range processed {
+			processedFiles[name] = true
+		}
+	}
+
+	batches := process.GroupBatches(cfg.App.FileTypes, files)
+	log.Printf("พบไฟล์ %d ไฟล์ ใน %d ชุด", len(files), len(batches))
+
+	// ประมวลผลทีละชุดจากเก่าไปใหม่ ข้ามชุดที่เคยประมวลผลแล้วและรอชุดที่ไฟล์ยังไม่ครบ
+	batchesProcessed, batchesFailed := 0, 0
+	for _, batch := range batches {
+		if ctx.Err() != nil {
+			log.Printf("ได้รับสัญญาณหยุด ไม่เริ่มชุดไฟล์ถัดไป")
+			break
+		}
+
+		processed, err := batchAlreadyProcessed(db, batch, processedFiles)
+		if err != nil {
+			return err
+		}
+		if processed {
+			continue
+		}
+
+		if missing := batch.Missing(cfg.App.FileTypes); len(missing) > 0 {
+			log.Printf("ชุดไฟล์ %s ยังไม่ครบ (ขาด %s) รอรอบถัดไป", batch.ID, strings.Join(missing, ", "))
+			continue
+		}
+
+		err = processBatch(cfg, db, attempts, sftpClient, batch)
+		if err == nil {
+			batchesProcessed++
+			continue
+		}
+		batchesFailed++
+
+		// ถ้าบันทึกลงฐานข้อมูลไม่ได้ ชุดถัดไปก็จะล้มเหลวเช่นกัน จึงหยุดรอบนี้แล้วรอรอบถัดไป
+		if errors.Is(err, process.ErrPersist) {
+			log.Printf("หยุดการประมวลผลรอบนี้เนื่องจากบันทึกข้อมูลลงฐานข้อมูลไม่ได้")
+			break
+		}
+		if errors.Is(err, process.ErrUnknownFileType) {
+			log.Printf("กรุณาตรวจสอบ app.file_types ให้ตรงกับประเภทไฟล์ที่รองรับ")
+		}
+	}
+
+	// ประมวลผลไฟล์ของ feed ที่กำหนดผ่าน schema แยกเป็นรายไฟล์
+	feedFilesProcessed, feedFilesFailed := 0, 0
+	if len(cfg.Feeds) > 0 && ctx.Err() == nil {
+		feedFilesProcessed, feedFilesFailed = processFeeds(ctx, cfg, db, attempts, sftpClient, s.registry)
+	}
+
+	pruneArchive(cfg)
+
+	endTime := time.Now()
+	duration := endTime.Sub(startTime)
+	log.Printf("การประมวลผลเสร็จสิ้น ประมวลผลชุดไฟล์ %d ชุด และไฟล์ของ feed %d ไฟล์ ใช้เวลา %s", batchesProcessed, feedFilesProcessed, duration)
+
+	if batchesFailed > 0 || feedFilesFailed > 0 {
+		return fmt.Errorf("ประมวลผลไม่สำเร็จ %d ชุด และไฟล์ของ feed %d ไฟล์", batchesFailed, feedFilesFailed)
+	}
+	return nil
+}
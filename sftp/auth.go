@@ -0,0 +1,164 @@
+package sftp
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mcmc/config"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// ชื่อวิธีการยืนยันตัวตนที่ใช้ใน SFTPConfig.AuthMethods
+const (
+	AuthPublicKey = "publickey"
+	AuthAgent     = "agent"
+	AuthPassword  = "password"
+)
+
+// hostKeyCallback สร้างฟังก์ชันตรวจสอบ host key ตามการตั้งค่า
+// ถ้ากำหนดทั้ง known_hosts และ fingerprint จะต้องผ่านทั้งสองแบบ
+func hostKeyCallback(cfg config.SFTPConfig) (ssh.HostKeyCallback, error) {
+	if cfg.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	var checks []ssh.HostKeyCallback
+
+	if cfg.KnownHostsFile != "" {
+		cb, err := knownhosts.New(cfg.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("ไม่สามารถอ่านไฟล์ known_hosts %s ได้: %v", cfg.KnownHostsFile, err)
+		}
+		checks = append(checks, func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			err := cb(hostname, remote, key)
+			var keyErr *knownhosts.KeyError
+			if errors.As(err, &keyErr) {
+				if len(keyErr.Want) == 0 {
+					return fmt.Errorf("ไม่พบ host key ของ %s ใน %s (fingerprint ที่ได้รับ: %s)", hostname, cfg.KnownHostsFile, ssh.FingerprintSHA256(key))
+				}
+				return fmt.Errorf("host key ของ %s ไม่ตรงกับ %s (fingerprint ที่ได้รับ: %s)", hostname, cfg.KnownHostsFile, ssh.FingerprintSHA256(key))
+			}
+			return err
+		})
+	}
+
+	if len(cfg.HostKeyFingerprints) > 0 {
+		checks = append(checks, func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			sha256Fingerprint := ssh.FingerprintSHA256(key)
+			md5Fingerprint := legacyFingerprint(key)
+			for _, pinned := range cfg.HostKeyFingerprints {
+				pinned = strings.TrimSpace(pinned)
+				if pinned == sha256Fingerprint || strings.EqualFold(strings.TrimPrefix(pinned, "MD5:"), md5Fingerprint) {
+					return nil
+				}
+			}
+			return fmt.Errorf("host key ของ %s ไม่ตรงกับ fingerprint ที่กำหนดไว้ (ได้รับ %s, คาดหวัง %s)",
+				hostname, sha256Fingerprint, strings.Join(cfg.HostKeyFingerprints, ", "))
+		})
+	}
+
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("ต้องกำหนด known_hosts_file หรือ host_key_fingerprints เพื่อตรวจสอบ host key")
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		for _, check := range checks {
+			if err := check(hostname, remote, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// legacyFingerprint คืน fingerprint แบบ MD5 (aa:bb:cc:...) สำหรับเทียบกับค่าที่ vendor ให้มาแบบเดิม
+func legacyFingerprint(key ssh.PublicKey) string {
+	sum := md5.Sum(key.Marshal())
+	hexStr := hex.EncodeToString(sum[:])
+	var parts []string
+	for i := 0; i < len(hexStr); i += 2 {
+		parts = append(parts, hexStr[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// authMethods สร้างรายการวิธียืนยันตัวตนตามลำดับใน AuthMethods
+// คืน io.Closer ของการเชื่อมต่อ ssh-agent (ถ้ามี) เพื่อให้ปิดเมื่อเลิกใช้งาน
+func authMethods(cfg config.SFTPConfig) ([]ssh.AuthMethod, io.Closer, error) {
+	var methods []ssh.AuthMethod
+	var agentConn io.Closer
+
+	for _, name := range cfg.EffectiveAuthMethods() {
+		switch name {
+		case AuthPublicKey:
+			signer, err := loadPrivateKey(cfg.PrivateKeyFile, cfg.PrivateKeyPassphrase)
+			if err != nil {
+				return nil, agentConn, err
+			}
+			methods = append(methods, ssh.PublicKeys(signer))
+
+		case AuthAgent:
+			socket := cfg.AgentSocket
+			if socket == "" {
+				socket = os.Getenv("SSH_AUTH_SOCK")
+			}
+			if socket == "" {
+				return nil, agentConn, fmt.Errorf("ไม่ได้กำหนด agent_socket และไม่พบ SSH_AUTH_SOCK")
+			}
+			conn, err := net.Dial("unix", socket)
+			if err != nil {
+				return nil, agentConn, fmt.Errorf("ไม่สามารถเชื่อมต่อกับ ssh-agent ได้: %v", err)
+			}
+			agentConn = conn
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+
+		case AuthPassword:
+			methods = append(methods, ssh.Password(cfg.Password))
+
+		default:
+			return nil, agentConn, fmt.Errorf("ไม่รู้จักวิธียืนยันตัวตน: %s", name)
+		}
+	}
+
+	if len(methods) == 0 {
+		return nil, agentConn, fmt.Errorf("ไม่มีวิธียืนยันตัวตนที่ใช้ได้")
+	}
+
+	return methods, agentConn, nil
+}
+
+// loadPrivateKey อ่าน private key (PEM หรือ OpenSSH) และถอดรหัสด้วย passphrase ถ้ามี
+func loadPrivateKey(path, passphrase string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่าน private key %s ได้: %v", path, err)
+	}
+
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(data)
+	}
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, fmt.Errorf("private key %s ถูกเข้ารหัส ต้องกำหนด private_key_passphrase", path)
+		}
+		if bytes.Contains(data, []byte("ENCRYPTED")) || passphrase != "" {
+			return nil, fmt.Errorf("ไม่สามารถถอดรหัส private key %s ได้ (passphrase อาจไม่ถูกต้อง)", path)
+		}
+		return nil, fmt.Errorf("ไม่สามารถอ่าน private key %s ได้: %v", path, err)
+	}
+
+	return signer, nil
+}
@@ -1,60 +1,142 @@
 package sftp
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"mcmc/config"
+	"mcmc/filetime"
+	"mcmc/retry"
+	"mcmc/secret"
+	"net"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
 // Client เป็นสำหรับจัดการการเชื่อมต่อ SFTP
+// ถ้าการเชื่อมต่อหลุดระหว่างใช้งาน จะเชื่อมต่อใหม่และลองคำสั่งนั้นใหม่ตาม retry policy
 type Client struct {
+	mu         sync.Mutex
 	sshClient  *ssh.Client
 	sftpClient *sftp.Client
+	agentConn  io.Closer
 	config     config.SFTPConfig
+	ctx        context.Context
+	policy     retry.Policy
+	broken     bool // ต้องเชื่อมต่อใหม่ก่อนใช้งานครั้งถัดไป
+	closed     bool
 }
 
-// NewClient สร้างการเชื่อมต่อใหม่กับ SFTP server
+// errClosed หมายถึงเรียกใช้ Client หลังจาก Close แล้ว
+var errClosed = errors.New("การเชื่อมต่อ SFTP ถูกปิดแล้ว")
+
+// NewClient สร้างการเชื่อมต่อใหม่กับ SFTP server (ไม่ลองใหม่เมื่อล้มเหลว)
 func NewClient(cfg config.SFTPConfig) (*Client, error) {
+	return Dial(context.Background(), cfg, retry.Policy{})
+}
+
+// Dial สร้างการเชื่อมต่อใหม่กับ SFTP server และลองใหม่ตาม policy เมื่อเกิดข้อผิดพลาดชั่วคราว
+// policy และ ctx ใช้กับการอ่านรายการไฟล์และการดาวน์โหลดด้วย ข้อผิดพลาดถาวร เช่น
+// ยืนยันตัวตนไม่ผ่านหรือ host key ไม่ตรง จะไม่ลองใหม่
+func Dial(ctx context.Context, cfg config.SFTPConfig, policy retry.Policy) (*Client, error) {
+	c := &Client{config: cfg, ctx: ctx, policy: policy}
+	if err := retry.Do(ctx, policy, "การเชื่อมต่อ SFTP server", c.connect); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect เชื่อมต่อกับ SSH server และสร้าง SFTP client
+func (c *Client) connect() error {
+	cfg := c.config
+
+	// ตรวจสอบ host key ตาม known_hosts หรือ fingerprint ที่กำหนดไว้
+	verify, err := hostKeyCallback(cfg)
+	if err != nil {
+		return retry.Permanent(err)
+	}
+
+	// เตรียมวิธียืนยันตัวตนตามลำดับที่กำหนด
+	auth, agentConn, err := authMethods(cfg)
+	if err != nil {
+		closeAgent(agentConn)
+		return retry.Permanent(fmt.Errorf("ไม่สามารถเตรียมการยืนยันตัวตนได้: %s", secret.Redact(err.Error(), cfg.Password, cfg.PrivateKeyPassphrase)))
+	}
+
 	// สร้าง SSH client config
 	sshConfig := &ssh.ClientConfig{
 		User: cfg.User,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(cfg.Password),
+		Auth: auth,
+		// host key ที่ไม่ตรงจะไม่ถูกต้องไม่ว่าจะลองกี่ครั้ง
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return retry.Permanent(verify(hostname, remote, key))
 		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 	}
 
 	// เชื่อมต่อกับ SSH server
 	sshClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:%s", cfg.Host, cfg.Port), sshConfig)
 	if err != nil {
-		return nil, fmt.Errorf("ไม่สามารถเชื่อมต่อกับ SSH server ได้: %v", err)
+		closeAgent(agentConn)
+		err = fmt.Errorf("ไม่สามารถเชื่อมต่อกับ SSH server ได้: %s", secret.Redact(err.Error(), cfg.Password, cfg.PrivateKeyPassphrase))
+		if isAuthError(err) {
+			return retry.Permanent(err)
+		}
+		return err
 	}
 
 	// สร้าง SFTP client
 	sftpClient, err := sftp.NewClient(sshClient)
 	if err != nil {
 		sshClient.Close()
-		return nil, fmt.Errorf("ไม่สามารถสร้าง SFTP client ได้: %v", err)
+		closeAgent(agentConn)
+		return fmt.Errorf("ไม่สามารถสร้าง SFTP client ได้: %s", secret.Redact(err.Error(), cfg.Password, cfg.PrivateKeyPassphrase))
 	}
 
-	return &Client{
-		sshClient:  sshClient,
-		sftpClient: sftpClient,
-		config:     cfg,
-	}, nil
+	c.sshClient = sshClient
+	c.sftpClient = sftpClient
+	c.agentConn = agentConn
+	c.broken = false
+	return nil
+}
+
+// isAuthError ตรวจสอบว่า err เกิดจากการยืนยันตัวตนหรือ host key ไม่ผ่าน
+// (ssh.Dial ไม่มีชนิดของ error สำหรับกรณีนี้ จึงตรวจจากข้อความ)
+func isAuthError(err error) bool {
+	return retry.IsPermanent(err) || strings.Contains(err.Error(), "unable to authenticate")
 }
 
-// Close ปิดการเชื่อมต่อทั้งหมด
+// Close ปิดการเชื่อมต่อทั้งหมด (เรียกซ้ำได้ และเรียกจาก goroutine อื่นระหว่างดาวน์โหลดได้)
 func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.disconnect()
+}
+
+func (c *Client) disconnect() error {
+	if c.sftpClient == nil {
+		return nil
+	}
 	err1 := c.sftpClient.Close()
 	err2 := c.sshClient.Close()
+	closeAgent(c.agentConn)
+	c.sftpClient, c.sshClient, c.agentConn = nil, nil, nil
 
 	if err1 != nil {
 		return err1
@@ -62,58 +144,357 @@ func (c *Client) Close() error {
 	return err2
 }
 
-// FindLatestFileByPrefix หาไฟล์ล่าสุดตาม prefix
-func (c *Client) FindLatestFileByPrefix(prefix string) (os.FileInfo, error) {
-	files, err := c.sftpClient.ReadDir(c.config.RemotePath)
+func closeAgent(conn io.Closer) {
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// Check ตรวจสอบว่ายังเข้าถึงโฟลเดอร์บนเซิร์ฟเวอร์ได้ และเชื่อมต่อใหม่ถ้าการเชื่อมต่อหลุด
+func (c *Client) Check() error {
+	return c.do("การตรวจสอบการเชื่อมต่อ SFTP", func(client *sftp.Client) error {
+		_, err := client.Stat(c.config.RemotePath)
+		return err
+	})
+}
+
+// do เรียก op ด้วย SFTP client ปัจจุบันและลองใหม่ตาม policy เมื่อเกิดข้อผิดพลาดชั่วคราว
+// หลังข้อผิดพลาดชั่วคราวการเชื่อมต่อจะถูกสร้างใหม่ก่อนลองครั้งถัดไป
+func (c *Client) do(name string, op func(client *sftp.Client) error) error {
+	return retry.Do(c.ctx, c.policy, name, func() error {
+		client, err := c.session()
+		if err != nil {
+			return err
+		}
+
+		err = classify(op(client))
+		if err != nil && !retry.IsPermanent(err) {
+			c.mu.Lock()
+			c.broken = true
+			c.mu.Unlock()
+		}
+		return err
+	})
+}
+
+// session คืน SFTP client ที่พร้อมใช้งาน และเชื่อมต่อใหม่ถ้าการเชื่อมต่อเดิมหลุด
+func (c *Client) session() (*sftp.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, retry.Permanent(errClosed)
+	}
+	if c.broken {
+		c.disconnect()
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	return c.sftpClient, nil
+}
+
+// classify ทำเครื่องหมาย error ของ SFTP ที่ลองใหม่แล้วไม่มีประโยชน์ (ไม่พบไฟล์ ไม่มีสิทธิ์ ไม่รองรับคำสั่ง)
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var status *sftp.StatusError
+	if errors.As(err, &status) {
+		switch status.FxCode() {
+		case sftp.ErrSSHFxNoSuchFile, sftp.ErrSSHFxPermissionDenied, sftp.ErrSSHFxOpUnsupported:
+			return retry.Permanent(err)
+		}
+	}
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) {
+		return retry.Permanent(err)
+	}
+	return err
+}
+
+// isNotExist ตรวจสอบว่า err หมายถึงไม่พบไฟล์บนเซิร์ฟเวอร์
+func isNotExist(err error) bool {
+	var status *sftp.StatusError
+	if errors.As(err, &status) {
+		return status.FxCode() == sftp.ErrSSHFxNoSuchFile
+	}
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// ListFilesByPrefix คืนรายการไฟล์ทั้งหมดที่ขึ้นต้นด้วย prefix เรียงจากเก่าไปใหม่
+// โดยใช้เวลาที่ฝังอยู่ในชื่อไฟล์ (ดู filetime.Timestamp) และใช้ชื่อไฟล์เป็นตัวตัดสินเมื่อเวลาเท่ากัน
+func (c *Client) ListFilesByPrefix(prefix string) ([]os.FileInfo, error) {
+	return c.ListFilesByPrefixIn(c.config.RemotePath, prefix)
+}
+
+// ListFilesByPrefixIn เหมือน ListFilesByPrefix แต่อ่านจากโฟลเดอร์ dir บนเซิร์ฟเวอร์
+func (c *Client) ListFilesByPrefixIn(dir, prefix string) ([]os.FileInfo, error) {
+	return c.listFiles(dir, func(name string) bool {
+		return strings.HasPrefix(name, prefix)
+	})
+}
+
+// ListFilesByPattern คืนรายการไฟล์ทั้งหมดที่ชื่อตรงกับ pattern (รูปแบบของ path.Match) เรียงจากเก่าไปใหม่
+func (c *Client) ListFilesByPattern(pattern string) ([]os.FileInfo, error) {
+	return c.listFiles(c.config.RemotePath, func(name string) bool {
+		ok, err := path.Match(pattern, name)
+		return err == nil && ok
+	})
+}
+
+func (c *Client) listFiles(dir string, match func(name string) bool) ([]os.FileInfo, error) {
+	var files []os.FileInfo
+	err := c.do("การอ่านรายการไฟล์", func(client *sftp.Client) error {
+		var err error
+		files, err = client.ReadDir(dir)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("ไม่สามารถอ่านรายการไฟล์ได้: %v", err)
 	}
 
 	var matchedFiles []os.FileInfo
 	for _, file := range files {
-		if strings.HasPrefix(file.Name(), prefix) {
+		if !file.IsDir() && !isSidecar(file.Name()) && match(file.Name()) {
 			matchedFiles = append(matchedFiles, file)
 		}
 	}
 
-	if len(matchedFiles) == 0 {
-		return nil, fmt.Errorf("ไม่พบไฟล์ที่ขึ้นต้นด้วย %s", prefix)
+	sort.SliceStable(matchedFiles, func(i, j int) bool {
+		ti, tj := filetime.Timestamp(matchedFiles[i]), filetime.Timestamp(matchedFiles[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return matchedFiles[i].Name() < matchedFiles[j].Name()
+	})
+
+	return matchedFiles, nil
+}
+
+// Exists ตรวจสอบว่ามีไฟล์ remoteFilePath บนเซิร์ฟเวอร์
+func (c *Client) Exists(remoteFilePath string) (bool, error) {
+	err := c.do("การตรวจสอบไฟล์ "+path.Base(remoteFilePath), func(client *sftp.Client) error {
+		_, err := client.Stat(remoteFilePath)
+		return err
+	})
+	if isNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// MoveFile ย้ายไฟล์บนเซิร์ฟเวอร์ไปไว้ในโฟลเดอร์ dir (สร้างโฟลเดอร์ถ้ายังไม่มี) และคืน path ใหม่
+// ถ้าปลายทางมีไฟล์ชื่อเดียวกันอยู่แล้วจะต่อท้ายชื่อด้วยเวลาที่ย้าย เพื่อไม่ให้ไฟล์เดิมถูกทับ
+func (c *Client) MoveFile(remoteFilePath, dir string) (string, error) {
+	name := path.Base(remoteFilePath)
+
+	// เลือกชื่อปลายทางครั้งเดียวก่อนย้าย เพราะเมื่อลองย้ายใหม่ ไฟล์ที่ปลายทางอาจเป็นไฟล์ที่ย้ายสำเร็จไปแล้วเอง
+	var target string
+	err := c.do("การเตรียมโฟลเดอร์ "+dir, func(client *sftp.Client) error {
+		if err := client.MkdirAll(dir); err != nil {
+			return err
+		}
+		target = path.Join(dir, name)
+		_, err := client.Stat(target)
+		if err == nil {
+			target = path.Join(dir, name+"."+time.Now().Format("20060102T150405"))
+			return nil
+		}
+		if isNotExist(err) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("ไม่สามารถย้ายไฟล์ %s ไปยัง %s ได้: %v", remoteFilePath, dir, err)
+	}
+
+	err = c.do("การย้าย "+name, func(client *sftp.Client) error {
+		err := client.Rename(remoteFilePath, target)
+		if isNotExist(err) {
+			// การย้ายครั้งก่อนอาจสำเร็จแล้วแต่การเชื่อมต่อหลุดก่อนได้รับคำตอบ
+			if _, statErr := client.Stat(target); statErr == nil {
+				return nil
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("ไม่สามารถย้ายไฟล์ %s ไปยัง %s ได้: %v", remoteFilePath, dir, err)
 	}
+	return target, nil
+}
 
-	// เรียงไฟล์ตามวันที่แก้ไข (ใหม่สุดอยู่ข้างบน)
-	sort.Slice(matchedFiles, func(i, j int) bool {
-		return matchedFiles[i].ModTime().After(matchedFiles[j].ModTime())
+// RemoveFile ลบไฟล์บนเซิร์ฟเวอร์ (ไฟล์ที่ไม่มีอยู่แล้วถือว่าลบสำเร็จ)
+func (c *Client) RemoveFile(remoteFilePath string) error {
+	err := c.do("การลบ "+path.Base(remoteFilePath), func(client *sftp.Client) error {
+		return client.Remove(remoteFilePath)
 	})
+	if err != nil && !isNotExist(err) {
+		return fmt.Errorf("ไม่สามารถลบไฟล์ %s ได้: %v", remoteFilePath, err)
+	}
+	return nil
+}
+
+// SidecarExtensions คือนามสกุลของไฟล์ checksum ที่ vendor ส่งมาคู่กับไฟล์ข้อมูล เรียงตามลำดับที่ใช้ตรวจสอบ
+// เช่น saleorder_item_xxx.csv.sha256 ไฟล์เหล่านี้ไม่ถูกนับเป็นไฟล์ข้อมูลในรายการไฟล์
+var SidecarExtensions = []string{".sha256", ".md5"}
+
+// isSidecar ตรวจสอบว่า name เป็นไฟล์ checksum
+func isSidecar(name string) bool {
+	for _, ext := range SidecarExtensions {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return true
+		}
+	}
+	return false
+}
 
-	return matchedFiles[0], nil
+// Download คือผลการดาวน์โหลดไฟล์หนึ่งไฟล์
+type Download struct {
+	Bytes    int64
+	SHA256   string // hex ของไฟล์ที่ดาวน์โหลด
+	Verified string // นามสกุลของไฟล์ checksum ที่ใช้ตรวจสอบ (ว่าง = ไม่ได้ตรวจสอบ)
 }
 
-// DownloadFile ดาวน์โหลดไฟล์จาก SFTP server
-func (c *Client) DownloadFile(remoteFilePath, localFilePath string) (int64, error) {
+// DownloadFile ดาวน์โหลดไฟล์จาก SFTP server ลงไฟล์ชั่วคราวในโฟลเดอร์เดียวกับ localFilePath
+// ตรวจสอบขนาดกับไฟล์บนเซิร์ฟเวอร์และ checksum จากไฟล์ .sha256/.md5 (ตาม sftp.checksum_sidecar)
+// แล้ว fsync และเปลี่ยนชื่อเป็น localFilePath ไฟล์ที่ไม่สมบูรณ์จึงไม่ถูกนำไปประมวลผล
+// และลองใหม่ตั้งแต่ต้นไฟล์เมื่อการเชื่อมต่อหลุดหรือได้ข้อมูลไม่ครบ
+func (c *Client) DownloadFile(remoteFilePath, localFilePath string) (*Download, error) {
+	// สร้างโฟลเดอร์บนเครื่องของเรา
+	localDir := filepath.Dir(localFilePath)
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		return nil, retry.Permanent(fmt.Errorf("ไม่สามารถสร้างโฟลเดอร์ %s ได้: %v", localDir, err))
+	}
+
+	var result *Download
+	err := c.do("การดาวน์โหลด "+path.Base(remoteFilePath), func(client *sftp.Client) error {
+		var err error
+		result, err = download(client, remoteFilePath, localFilePath, c.config.ChecksumSidecar)
+		return err
+	})
+	return result, err
+}
+
+// download คัดลอกไฟล์บนเซิร์ฟเวอร์มาแทนไฟล์บนเครื่อง ผ่านไฟล์ชั่วคราวที่ถูกลบถ้าไม่สำเร็จ
+// ข้อผิดพลาดฝั่งเครื่องของเราและ checksum ที่ไม่ตรงถูกทำเครื่องหมายเป็นถาวรเพราะการเชื่อมต่อใหม่ไม่ช่วยให้สำเร็จ
+func download(client *sftp.Client, remoteFilePath, localFilePath, sidecarMode string) (*Download, error) {
 	// เปิดไฟล์บนเซิร์ฟเวอร์
-	remoteFile, err := c.sftpClient.Open(remoteFilePath)
+	remoteFile, err := client.Open(remoteFilePath)
 	if err != nil {
-		return 0, fmt.Errorf("ไม่สามารถเปิดไฟล์บนเซิร์ฟเวอร์ได้: %v", err)
+		return nil, fmt.Errorf("ไม่สามารถเปิดไฟล์บนเซิร์ฟเวอร์ได้: %w", err)
 	}
 	defer remoteFile.Close()
 
-	// สร้างไฟล์บนเครื่องของเรา
-	localDir := filepath.Dir(localFilePath)
-	if _, err := os.Stat(localDir); os.IsNotExist(err) {
-		os.MkdirAll(localDir, 0755)
+	info, err := remoteFile.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่านข้อมูลไฟล์บนเซิร์ฟเวอร์ได้: %w", err)
 	}
 
-	localFile, err := os.Create(localFilePath)
+	ext, expected, err := readSidecar(client, remoteFilePath, sidecarMode)
 	if err != nil {
-		return 0, fmt.Errorf("ไม่สามารถสร้างไฟล์บนเครื่องได้: %v", err)
+		return nil, err
 	}
-	defer localFile.Close()
 
-	// คัดลอกข้อมูลจากไฟล์บนเซิร์ฟเวอร์มายังไฟล์บนเครื่อง
-	n, err := io.Copy(localFile, remoteFile)
+	// สร้างไฟล์ชั่วคราวบนเครื่องของเรา (โฟลเดอร์เดียวกันเพื่อให้เปลี่ยนชื่อได้ในครั้งเดียว)
+	tmp, err := os.CreateTemp(filepath.Dir(localFilePath), "."+filepath.Base(localFilePath)+".*.part")
 	if err != nil {
-		return 0, fmt.Errorf("ไม่สามารถคัดลอกข้อมูลได้: %v", err)
+		return nil, retry.Permanent(fmt.Errorf("ไม่สามารถสร้างไฟล์บนเครื่องได้: %v", err))
 	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
 
-	return n, nil
-}
\ No newline at end of file
+	// คัดลอกข้อมูลจากไฟล์บนเซิร์ฟเวอร์และคำนวณ checksum ไปพร้อมกัน
+	sha := sha256.New()
+	sum := md5.New()
+	n, err := io.Copy(io.MultiWriter(tmp, sha, sum), remoteFile)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถคัดลอกข้อมูลได้: %w", err)
+	}
+	if n != info.Size() {
+		return nil, fmt.Errorf("ได้ข้อมูล %d bytes ไม่ตรงกับขนาดไฟล์บนเซิร์ฟเวอร์ %d bytes", n, info.Size())
+	}
+
+	result := &Download{Bytes: n, SHA256: hex.EncodeToString(sha.Sum(nil))}
+	if ext != "" {
+		actual := result.SHA256
+		if ext == ".md5" {
+			actual = hex.EncodeToString(sum.Sum(nil))
+		}
+		if actual != expected {
+			return nil, retry.Permanent(fmt.Errorf("checksum ไม่ตรงกับไฟล์ %s%s (คาดว่า %s ได้ %s)", path.Base(remoteFilePath), ext, expected, actual))
+		}
+		result.Verified = ext
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return nil, retry.Permanent(fmt.Errorf("ไม่สามารถบันทึกไฟล์ลงดิสก์ได้: %v", err))
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, retry.Permanent(fmt.Errorf("ไม่สามารถบันทึกไฟล์ลงดิสก์ได้: %v", err))
+	}
+	if err := os.Rename(tmp.Name(), localFilePath); err != nil {
+		return nil, retry.Permanent(fmt.Errorf("ไม่สามารถเปลี่ยนชื่อไฟล์เป็น %s ได้: %v", localFilePath, err))
+	}
+	return result, nil
+}
+
+// readSidecar อ่าน checksum ที่คาดไว้จากไฟล์ .sha256 หรือ .md5 ที่อยู่คู่กับ remoteFilePath
+// และคืนนามสกุลของไฟล์ที่ใช้ (ว่าง = ไม่ตรวจสอบ) mode คือ none, optional หรือ required
+func readSidecar(client *sftp.Client, remoteFilePath, mode string) (string, string, error) {
+	if mode == "" || mode == "none" {
+		return "", "", nil
+	}
+
+	for _, ext := range SidecarExtensions {
+		f, err := client.Open(remoteFilePath + ext)
+		if isNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("ไม่สามารถเปิดไฟล์ %s%s ได้: %w", path.Base(remoteFilePath), ext, err)
+		}
+		content, err := io.ReadAll(io.LimitReader(f, 4096))
+		f.Close()
+		if err != nil {
+			return "", "", fmt.Errorf("ไม่สามารถอ่านไฟล์ %s%s ได้: %w", path.Base(remoteFilePath), ext, err)
+		}
+
+		expected, err := parseChecksum(string(content), ext)
+		if err != nil {
+			return "", "", retry.Permanent(fmt.Errorf("ไฟล์ %s%s ไม่ถูกต้อง: %v", path.Base(remoteFilePath), ext, err))
+		}
+		return ext, expected, nil
+	}
+
+	if mode == "required" {
+		return "", "", retry.Permanent(fmt.Errorf("ไม่พบไฟล์ checksum ของ %s (%s)", path.Base(remoteFilePath), strings.Join(SidecarExtensions, ", ")))
+	}
+	return "", "", nil
+}
+
+// parseChecksum อ่าน checksum จากเนื้อหาของไฟล์ checksum ในรูปแบบ "<hex>", "<hex>  <file>"
+// (sha256sum, md5sum) หรือ "SHA256 (<file>) = <hex>" (BSD) และตรวจสอบความยาวตามชนิด ext
+func parseChecksum(content, ext string) (string, error) {
+	content = strings.TrimSpace(content)
+	if i := strings.LastIndex(content, "= "); i >= 0 {
+		content = content[i+2:]
+	}
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return "", errors.New("ไม่มี checksum")
+	}
+	sum := strings.ToLower(strings.TrimPrefix(fields[0], "\\"))
+
+	length := sha256.Size * 2
+	if ext == ".md5" {
+		length = md5.Size * 2
+	}
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != length {
+		return "", fmt.Errorf("checksum %q ไม่ใช่ hex ยาว %d ตัวอักษร", sum, length)
+	}
+	return sum, nil
+}
@@ -0,0 +1,58 @@
+package sftp
+
+import "testing"
+
+func TestParseChecksum(t *testing.T) {
+	const (
+		sha = "d052db7933d4a4639b2959c1d79dca1c652f736d95028eac8528cadde5e3d0ff"
+		md5 = "9e107d9d372bb6826bd81d3542a419d6"
+	)
+
+	tests := []struct {
+		name    string
+		content string
+		ext     string
+		want    string
+		wantErr bool
+	}{
+		{name: "hex อย่างเดียว", content: sha + "\n", ext: ".sha256", want: sha},
+		{name: "ตัวพิมพ์ใหญ่", content: "D052DB7933D4A4639B2959C1D79DCA1C652F736D95028EAC8528CADDE5E3D0FF", ext: ".sha256", want: sha},
+		{name: "sha256sum", content: sha + "  saleorder_item_20250514.csv\n", ext: ".sha256", want: sha},
+		{name: "sha256sum แบบ binary", content: sha + " *saleorder_item_20250514.csv", ext: ".sha256", want: sha},
+		{name: "sha256sum ที่ escape ชื่อไฟล์", content: "\\" + sha + "  saleorder\\\\item.csv", ext: ".sha256", want: sha},
+		{name: "BSD", content: "SHA256 (saleorder_item_20250514.csv) = " + sha, ext: ".sha256", want: sha},
+		{name: "md5sum", content: md5 + "  saleorder_item_20250514.csv", ext: ".md5", want: md5},
+		{name: "md5 แบบ BSD", content: "MD5 (saleorder_item_20250514.csv) = " + md5, ext: ".md5", want: md5},
+		{name: "ว่าง", content: " \n", ext: ".sha256", wantErr: true},
+		{name: "ความยาวไม่ตรงกับชนิด", content: md5, ext: ".sha256", wantErr: true},
+		{name: "sha256 ในไฟล์ .md5", content: sha, ext: ".md5", wantErr: true},
+		{name: "ไม่ใช่ hex", content: "z" + sha[1:], ext: ".sha256", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseChecksum(tt.content, tt.ext)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseChecksum(%q, %q) error = %v ต้องการ error = %v", tt.content, tt.ext, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseChecksum(%q, %q) = %q ต้องการ %q", tt.content, tt.ext, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSidecar(t *testing.T) {
+	tests := map[string]bool{
+		"saleorder_item_20250514.csv":        false,
+		"saleorder_item_20250514.csv.sha256": true,
+		"saleorder_item_20250514.csv.SHA256": true,
+		"saleorder_item_20250514.csv.md5":    true,
+		"sha256_report.csv":                  false,
+	}
+	for name, want := range tests {
+		if got := isSidecar(name); got != want {
+			t.Errorf("isSidecar(%q) = %v ต้องการ %v", name, got, want)
+		}
+	}
+}